@@ -1,6 +1,7 @@
 package cmd
 
 import (
+	"fmt"
 	"io/ioutil"
 
 	yaml "gopkg.in/yaml.v2"
@@ -8,18 +9,99 @@ import (
 
 // Config Store values read from config.yaml file
 type Config struct {
-	PackageName string   `yaml:"packageName"`
-	Generate    Generate `yaml:"generate"`
+	PackageName   string        `yaml:"packageName"`
+	Generate      Generate      `yaml:"generate"`
+	Migrate       Migrate       `yaml:"migrate"`
+	Authorisation Authorisation `yaml:"authorisation"`
+	Links         []Link        `yaml:"links"`
+}
+
+// Link A many-to-many relationship between two models, stored in a join table
+type Link struct {
+	Model1  string `yaml:"model1"`  // Name of a model configured under generate.postgres
+	Model2  string `yaml:"model2"`  // Name of a model configured under generate.postgres
+	Table   string `yaml:"table"`   // Join table
+	Column1 string `yaml:"column1"` // Column in the join table referencing model1.  Defaults to {model1}_id
+	Column2 string `yaml:"column2"` // Column in the join table referencing model2.  Defaults to {model2}_id
+	Plural1 string `yaml:"plural1"` // Field listing model1 on model2.  Defaults to the plural name from resolvers
+	Plural2 string `yaml:"plural2"` // Field listing model2 on model1.  Defaults to the plural name from resolvers
+	// Policy Path under which link policies are found.  Both models are
+	// checked, at {Policy}.{camel model name}.allow
+	Policy string `yaml:"policy"`
+}
+
+// Authorisation Settings for generating the authorisation package, which
+// checks models against OPA policies
+type Authorisation struct {
+	// QueryPolicy Path under which model policies are found.  Each model is
+	// checked against {QueryPolicy}.{camel model name}.allow
+	QueryPolicy string `yaml:"queryPolicy"`
+	// InputBuilder Function in the authorisation package that adds values
+	// shared by every policy input.  When empty, one is generated that adds
+	// the user from context
+	InputBuilder string               `yaml:"inputBuilder"`
+	Models       []AuthorisationModel `yaml:"models"`
+}
+
+// AuthorisationModel A model to generate authorisation functions for
+type AuthorisationModel struct {
+	ModelName string `yaml:"modelName"` // Name of a model configured under generate.postgres
+	Policy    string `yaml:"policy"`    // Overrides the policy checked for this model
+}
+
+// Migrate Settings used by the migrate command
+type Migrate struct {
+	Folder string `yaml:"folder"` // Folder containing the migration files
+	Table  string `yaml:"table"`  // Table used to record applied migrations
 }
 
 // Generate Stores generate values from yaml
 type Generate struct {
 	// ProtectGnorm When true, prevents gnorm's default files from being
 	// overwritten
-	ProtectGnorm bool               `yaml:"protectGnorm"`
-	SchemaName   string             `yaml:"schemaName"`
-	Resolvers    []ResolverGenerate `yaml:"resolvers"`
-	Postgres     []PostgresGenerate `yaml:"postgres"`
+	ProtectGnorm bool `yaml:"protectGnorm"`
+	// TemplateDir Folder containing project overrides for estack's built-in
+	// templates, using the same layout.  E.g., a file at
+	// {TemplateDir}/loader/gen.gotmpl replaces loader/gen.gotmpl
+	TemplateDir string `yaml:"templateDir"`
+	// Database Type of database the generated code uses, either postgres or
+	// mysql.  Should match DBType in gnorm.toml
+	Database   string             `yaml:"database"`
+	SchemaName string             `yaml:"schemaName"`
+	Resolvers  []ResolverGenerate `yaml:"resolvers"`
+	Postgres   []PostgresGenerate `yaml:"postgres"`
+}
+
+const (
+	databasePostgres = "postgres"
+	databaseMySQL    = "mysql"
+)
+
+// LoaderType Name of the loader struct for the configured database, which
+// generated loader functions are attached to
+func (c Config) LoaderType() string {
+	if c.Generate.Database == databaseMySQL {
+		return "MySQLLoader"
+	}
+
+	return "PostgresLoader"
+}
+
+// flavourTemplate Returns the name of the template to use for the configured
+// database.  Templates that differ by database are kept under a folder named
+// after it, e.g. mysql/templates/table.gotmpl, and fall back to the shared
+// template when there is no such version
+func (c Config) flavourTemplate(name string) string {
+	if c.Generate.Database == databasePostgres {
+		return name
+	}
+
+	flavour := c.Generate.Database + "/" + name
+	if _, err := readTemplate(flavour); err == nil {
+		return flavour
+	}
+
+	return name
 }
 
 // ResolverGenerate Which resolver related things to generate code for
@@ -32,6 +114,32 @@ type ResolverGenerate struct {
 	Update            bool   `yaml:"update"`        // Build an update function
 	PrepareCreate     bool   `yaml:"prepareCreate"` // Provide a prepare function for you (set to false if you want to set one yourself)
 	Query             bool   `yaml:"query"`         // Creates a queryX function used for pagination via a connections type method
+	Delete            bool   `yaml:"delete"`        // Build a delete mutation, which needs delete set for the model under postgres
+	// DeletePolicy Policy checked before deleting or restoring.  Defaults to
+	// data.api.delete.{camel model name}.allow
+	DeletePolicy string `yaml:"deletePolicy,omitempty"`
+	// Restore Build a restore mutation and honour includeDeleted in the
+	// filter, which needs deletedAt set for the model under postgres
+	Restore bool `yaml:"restore,omitempty"`
+	// HistoryPolicy Policy checked before listing the changes recorded for a
+	// model with audit set.  Defaults to
+	// data.api.history.{camel model name}.allow
+	HistoryPolicy string `yaml:"historyPolicy,omitempty"`
+	// Subscribe Build xChanged and xCreated subscriptions, fed by a trigger
+	// that generate adds a migration for.  Needs query, as xCreated uses
+	// the same filter, and postgres
+	Subscribe bool `yaml:"subscribe,omitempty"`
+	// SubscribePolicy Policy checked before sending each change to a
+	// subscriber.  Defaults to data.api.subscribe.{camel model name}.allow
+	SubscribePolicy string `yaml:"subscribePolicy,omitempty"`
+	// OrderBy Have queryX take a list of XOrder inputs, each giving a field
+	// along with its own direction and NULL placement, in place of
+	// sortField and sortDirection.  Needs query
+	OrderBy bool `yaml:"orderBy,omitempty"`
+	// Where Have queryX take an XWhere input, generated by gnorm from the
+	// table's columns, in place of XFilter, so that no filterX function is
+	// needed.  Needs query
+	Where bool `yaml:"where,omitempty"`
 }
 
 // PostgresGenerate Which postgres helper functions to generate code for
@@ -43,6 +151,57 @@ type PostgresGenerate struct {
 	PK             string `yaml:"primaryKey"`     // Go struct for database name for primary key field
 	PrimaryKeyType string `yaml:"primaryKeyType"` // Go type for primary key
 	Create         bool   `yaml:"create"`         // Generate create/update related functions
+	Delete         bool   `yaml:"delete"`         // Generate a delete function
+	// Cascade Child tables whose rows referring to the model are deleted
+	// along with it, in the order listed
+	Cascade []CascadeDelete `yaml:"cascade,omitempty"`
+	// DeletedAt Column marking rows as soft deleted, matching DeletedAtField
+	// in gnorm.toml.  When set, deleting sets the column rather than
+	// removing the row, and a restore function is generated
+	DeletedAt string `yaml:"deletedAt,omitempty"`
+	// Version Column checked when updating, so that changes made since the
+	// row was read aren't overwritten.  Updates must give the version they
+	// expect, and fail with a conflict error if it's no longer current
+	Version string `yaml:"version,omitempty"`
+	// VersionType Go type of the version column, either int (the default),
+	// which is incremented on each update, or time.Time, which is set to the
+	// current time
+	VersionType string `yaml:"versionType,omitempty"`
+	// Audit Record each change to the model's rows, with their values before
+	// and after, in a {table}_history table.  Generate adds a migration
+	// creating the table if there isn't one
+	Audit bool `yaml:"audit,omitempty"`
+	// Children Models referred to by the model's columns, whose rows can be
+	// created or updated along with it, given as nested objects in its input
+	Children []NestedChild `yaml:"children,omitempty"`
+	// Search Columns matched by a search, which generate adds a migration
+	// for, combining them into a generated tsvector column named
+	// search_vector, with a GIN index.  Resolvers with query take a search
+	// argument and can sort by rank.  SearchField in gnorm.toml must name the
+	// column, so that gnorm leaves it out.  Needs postgres
+	Search []string `yaml:"search,omitempty"`
+	// SearchConfig Text search configuration the search column is made with,
+	// such as simple.  Defaults to english
+	SearchConfig string `yaml:"searchConfig,omitempty"`
+}
+
+const (
+	versionInt  = "int"
+	versionTime = "time.Time"
+)
+
+// CascadeDelete A child table with rows to delete along with a model
+type CascadeDelete struct {
+	Table  string `yaml:"table"`  // Child table, in the same schema as the model
+	Column string `yaml:"column"` // Column referring to the model.  Defaults to {model}_id
+}
+
+// NestedChild A model whose rows can be created or updated from a nested
+// object in another model's input, which refers to the row by a column
+type NestedChild struct {
+	Field  string `yaml:"field"`  // Input key holding the nested object, such as person
+	Model  string `yaml:"model"`  // Model under generate.postgres, which must set create
+	Column string `yaml:"column"` // Column referring to the child's row.  Defaults to {field}_id
 }
 
 func readConfig(filename string) (Config, error) {
@@ -51,15 +210,38 @@ func readConfig(filename string) (Config, error) {
 		return Config{}, err
 	}
 
-	var config Config
+	config := defaultConfig()
 	err = yaml.Unmarshal(input, &config)
 	if err != nil {
 		return config, err
 	}
 
+	switch config.Generate.Database {
+	case databasePostgres, databaseMySQL:
+	default:
+		return config, fmt.Errorf("Unsupported database '%s' in generate.database.  Use %s or %s", config.Generate.Database, databasePostgres, databaseMySQL)
+	}
+
 	return config, err
 }
 
+// defaultConfig Returns a config with default values set for anything that
+// may be left out of config.yaml
+func defaultConfig() Config {
+	return Config{
+		Generate: Generate{
+			Database: databasePostgres,
+		},
+		Migrate: Migrate{
+			Folder: "migrations",
+			Table:  "schema_version",
+		},
+		Authorisation: Authorisation{
+			QueryPolicy: "data.api.query",
+		},
+	}
+}
+
 // UnmarshalYAML Allows us to set default values when not provided in the
 // config
 func (r *ResolverGenerate) UnmarshalYAML(unmarshal func(interface{}) error) error {
@@ -76,3 +258,22 @@ func (r *ResolverGenerate) UnmarshalYAML(unmarshal func(interface{}) error) erro
 	*r = ResolverGenerate(raw)
 	return nil
 }
+
+// UnmarshalYAML Allows models to be listed by name alone
+func (a *AuthorisationModel) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var name string
+	if err := unmarshal(&name); err == nil {
+		*a = AuthorisationModel{ModelName: name}
+		return nil
+	}
+
+	type rawA AuthorisationModel
+
+	var raw rawA
+	if err := unmarshal(&raw); err != nil {
+		return err
+	}
+
+	*a = AuthorisationModel(raw)
+	return nil
+}
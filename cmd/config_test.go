@@ -0,0 +1,65 @@
+package cmd
+
+import (
+	"testing"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+func TestAuthorisationConfig(t *testing.T) {
+	input := `
+authorisation:
+  models:
+  - Todo
+  - modelName: User
+    policy: data.api.query.user.read
+`
+
+	config := defaultConfig()
+	err := yaml.Unmarshal([]byte(input), &config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a := config.Authorisation
+	if a.QueryPolicy != "data.api.query" {
+		t.Errorf("Expected default query policy, but had '%s'", a.QueryPolicy)
+	}
+
+	expected := []AuthorisationModel{
+		{ModelName: "Todo"},
+		{ModelName: "User", Policy: "data.api.query.user.read"},
+	}
+
+	if len(a.Models) != len(expected) {
+		t.Fatalf("Expected %d models, but had %d", len(expected), len(a.Models))
+	}
+
+	for i, e := range expected {
+		if a.Models[i] != e {
+			t.Errorf("Expected model %+v, but had %+v", e, a.Models[i])
+		}
+	}
+}
+
+var flavourTemplateCases = []struct {
+	Database string
+	Name     string
+	Expected string
+}{
+	{databasePostgres, "templates/table.gotmpl", "templates/table.gotmpl"},
+	{databaseMySQL, "templates/table.gotmpl", "mysql/templates/table.gotmpl"},
+	{databaseMySQL, "templates/enum.gotmpl", "templates/enum.gotmpl"},
+	{databaseMySQL, "loader/gen.gotmpl", "loader/gen.gotmpl"},
+}
+
+func TestFlavourTemplate(t *testing.T) {
+	for _, c := range flavourTemplateCases {
+		config := defaultConfig()
+		config.Generate.Database = c.Database
+
+		if name := config.flavourTemplate(c.Name); name != c.Expected {
+			t.Errorf("Expected %s for %s with %s, but had %s", c.Expected, c.Name, c.Database, name)
+		}
+	}
+}
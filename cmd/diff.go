@@ -0,0 +1,171 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// diffContext Number of unchanged lines shown around each change
+const diffContext = 3
+
+// diffOp A single line in an edit script
+type diffOp struct {
+	Kind byte // ' ', '-' or '+'
+	Line string
+}
+
+// unifiedDiff Returns a unified diff turning a into b, or an empty string if
+// they are the same
+func unifiedDiff(fromName string, toName string, a []byte, b []byte) string {
+	if bytes.Equal(a, b) {
+		return ""
+	}
+
+	ops := diffLines(splitLines(a), splitLines(b))
+
+	var out strings.Builder
+	fmt.Fprintf(&out, "--- %s\n+++ %s\n", fromName, toName)
+
+	// Group the edit script into hunks, each with up to diffContext lines of
+	// context either side of the changes
+	for start := 0; start < len(ops); {
+		// Find the next change:
+		for start < len(ops) && ops[start].Kind == ' ' {
+			start++
+		}
+		if start == len(ops) {
+			break
+		}
+
+		first := start - diffContext
+		if first < 0 {
+			first = 0
+		}
+
+		// Extend the hunk until there is a gap of unchanged lines too large to
+		// bridge
+		end := start
+		for end < len(ops) {
+			if ops[end].Kind != ' ' {
+				end++
+				continue
+			}
+
+			gap := end
+			for gap < len(ops) && ops[gap].Kind == ' ' {
+				gap++
+			}
+
+			if gap == len(ops) || gap-end > diffContext*2 {
+				break
+			}
+			end = gap
+		}
+
+		last := end + diffContext
+		if last > len(ops) {
+			last = len(ops)
+		}
+
+		writeHunk(&out, ops, first, last)
+		start = last
+	}
+
+	return out.String()
+}
+
+// writeHunk Writes ops[first:last] as a single hunk, including the line
+// numbers it applies to
+func writeHunk(out *strings.Builder, ops []diffOp, first int, last int) {
+	// Line numbers in each file at the start of the hunk:
+	aLine, bLine := 1, 1
+	for _, op := range ops[:first] {
+		if op.Kind != '+' {
+			aLine++
+		}
+		if op.Kind != '-' {
+			bLine++
+		}
+	}
+
+	aCount, bCount := 0, 0
+	for _, op := range ops[first:last] {
+		if op.Kind != '+' {
+			aCount++
+		}
+		if op.Kind != '-' {
+			bCount++
+		}
+	}
+
+	// An empty range is identified by the line before it
+	if aCount == 0 {
+		aLine--
+	}
+	if bCount == 0 {
+		bLine--
+	}
+
+	fmt.Fprintf(out, "@@ -%d,%d +%d,%d @@\n", aLine, aCount, bLine, bCount)
+	for _, op := range ops[first:last] {
+		fmt.Fprintf(out, "%c%s\n", op.Kind, op.Line)
+	}
+}
+
+// diffLines Returns the shortest edit script from a to b, based on their
+// longest common subsequence
+func diffLines(a []string, b []string) []diffOp {
+	// lcs[i][j] is the length of the longest common subsequence of a[i:] and
+	// b[j:]
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	var ops []diffOp
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] == b[j]:
+			ops = append(ops, diffOp{Kind: ' ', Line: a[i]})
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			ops = append(ops, diffOp{Kind: '-', Line: a[i]})
+			i++
+		default:
+			ops = append(ops, diffOp{Kind: '+', Line: b[j]})
+			j++
+		}
+	}
+
+	for ; i < len(a); i++ {
+		ops = append(ops, diffOp{Kind: '-', Line: a[i]})
+	}
+	for ; j < len(b); j++ {
+		ops = append(ops, diffOp{Kind: '+', Line: b[j]})
+	}
+
+	return ops
+}
+
+func splitLines(s []byte) []string {
+	if len(s) == 0 {
+		return nil
+	}
+
+	return strings.Split(strings.TrimSuffix(string(s), "\n"), "\n")
+}
@@ -0,0 +1,44 @@
+package cmd
+
+import "testing"
+
+var unifiedDiffCases = []struct {
+	Name     string
+	A        string
+	B        string
+	Expected string
+}{
+	{
+		Name:     "unchanged",
+		A:        "a\nb\n",
+		B:        "a\nb\n",
+		Expected: "",
+	},
+	{
+		Name:     "created",
+		A:        "",
+		B:        "a\nb\n",
+		Expected: "--- from\n+++ to\n@@ -0,0 +1,2 @@\n+a\n+b\n",
+	},
+	{
+		Name:     "changedLine",
+		A:        "1\n2\n3\n4\n5\n6\n7\n8\n9\n",
+		B:        "1\n2\n3\n4\nfive\n6\n7\n8\n9\n",
+		Expected: "--- from\n+++ to\n@@ -2,7 +2,7 @@\n 2\n 3\n 4\n-5\n+five\n 6\n 7\n 8\n",
+	},
+	{
+		Name:     "separateHunks",
+		A:        "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\n12\n",
+		B:        "one\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\ntwelve\n",
+		Expected: "--- from\n+++ to\n@@ -1,4 +1,4 @@\n-1\n+one\n 2\n 3\n 4\n@@ -9,4 +9,4 @@\n 9\n 10\n 11\n-12\n+twelve\n",
+	},
+}
+
+func TestUnifiedDiff(t *testing.T) {
+	for _, c := range unifiedDiffCases {
+		d := unifiedDiff("from", "to", []byte(c.A), []byte(c.B))
+		if d != c.Expected {
+			t.Errorf("%s: expected diff:\n%s\nbut had:\n%s", c.Name, c.Expected, d)
+		}
+	}
+}
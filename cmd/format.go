@@ -0,0 +1,123 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"go/scanner"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/tools/imports"
+)
+
+// generateErrors Errors collected while generating, so that every problem can
+// be reported at once rather than stopping at the first
+type generateErrors []error
+
+// Error Lists each error on its own line
+func (e generateErrors) Error() string {
+	var lines []string
+	for _, err := range e {
+		lines = append(lines, err.Error())
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// add Appends err, flattening any nested generateErrors.  A nil err is
+// ignored
+func (e *generateErrors) add(err error) {
+	if err == nil {
+		return
+	}
+
+	if list, ok := err.(generateErrors); ok {
+		*e = append(*e, list...)
+		return
+	}
+
+	*e = append(*e, err)
+}
+
+// err Returns nil if there were no errors
+func (e generateErrors) err() error {
+	if len(e) == 0 {
+		return nil
+	}
+
+	return e
+}
+
+// formatGo Formats src and fixes its imports, as though it were located at
+// fileName.  Syntax errors are reported by go/format before any time is spent
+// resolving imports
+func formatGo(fileName string, src []byte) ([]byte, error) {
+	formatted, err := format.Source(src)
+	if err != nil {
+		return nil, err
+	}
+
+	return imports.Process(fileName, formatted, nil)
+}
+
+// sourceErrors Describes the syntax errors in generated source, quoting the
+// offending lines so they can be traced back to the template that produced
+// them
+func sourceErrors(fileName string, templateName string, src []byte, err error) error {
+	list, ok := err.(scanner.ErrorList)
+	if !ok {
+		return fmt.Errorf("%s (from template %s): %s", fileName, templateName, err)
+	}
+
+	lines := strings.Split(string(src), "\n")
+
+	var errs generateErrors
+	for _, e := range list {
+		line := ""
+		if e.Pos.Line > 0 && e.Pos.Line <= len(lines) {
+			line = strings.TrimSpace(lines[e.Pos.Line-1])
+		}
+
+		errs.add(fmt.Errorf("%s:%d:%d (from template %s): %s\n\t%s", fileName, e.Pos.Line, e.Pos.Column, templateName, e.Msg, line))
+	}
+
+	return errs
+}
+
+// formatFolder Formats and fixes the imports of every Go file in folder
+func formatFolder(folder string) error {
+	var errs generateErrors
+
+	err := filepath.Walk(folder, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if info.IsDir() || filepath.Ext(path) != ".go" {
+			return nil
+		}
+
+		src, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+
+		out, err := formatGo(path, src)
+		if err != nil {
+			errs.add(err)
+			return nil
+		}
+
+		if bytes.Equal(src, out) {
+			return nil
+		}
+
+		return ioutil.WriteFile(path, out, info.Mode())
+	})
+	errs.add(err)
+
+	return errs.err()
+}
@@ -0,0 +1,45 @@
+package cmd
+
+import (
+	"fmt"
+	"go/format"
+	"strings"
+	"testing"
+)
+
+func TestSourceErrors(t *testing.T) {
+	src := []byte("package loader\n\nfunc GetTodo() {\n\treturn fmt.Println(\n}\n")
+
+	_, err := format.Source(src)
+	if err == nil {
+		t.Fatal("Expected invalid source to fail formatting")
+	}
+
+	msg := sourceErrors("loader/gen_todo.go", "loader/gen.gotmpl", src, err).Error()
+
+	for _, expected := range []string{"loader/gen_todo.go:5:1", "loader/gen.gotmpl", "\t}"} {
+		if !strings.Contains(msg, expected) {
+			t.Errorf("Expected error to contain '%s', but had: %s", expected, msg)
+		}
+	}
+}
+
+func TestGenerateErrors(t *testing.T) {
+	var errs generateErrors
+	errs.add(nil)
+
+	if errs.err() != nil {
+		t.Fatalf("Expected no error, but had %s", errs.err())
+	}
+
+	errs.add(fmt.Errorf("first"))
+	errs.add(generateErrors{fmt.Errorf("second"), fmt.Errorf("third")})
+
+	if len(errs) != 3 {
+		t.Fatalf("Expected nested errors to be flattened into 3, but had %d", len(errs))
+	}
+
+	if errs.Error() != "first\nsecond\nthird" {
+		t.Errorf("Unexpected error message: %s", errs.Error())
+	}
+}
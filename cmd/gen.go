@@ -1,27 +1,39 @@
 package cmd
 
 import (
+	"bytes"
 	"fmt"
+	"go/parser"
+	"go/token"
 	"io/ioutil"
 	"log"
 	"os"
-	"os/exec"
-	"path"
-	"runtime"
+	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
 	"text/template"
-	"time"
 
 	"github.com/codemodus/kace"
 	"github.com/urfave/cli"
 	gcli "gnorm.org/gnorm/cli"
 	"gnorm.org/gnorm/environ"
+	yaml "gopkg.in/yaml.v2"
 )
 
 var loaderTemplate *template.Template
 var resolverTemplate *template.Template
 var postgresTemplate *template.Template
 var filterTemplate *template.Template
+var authorisationTemplate *template.Template
+var authorisationSharedTemplate *template.Template
+var loaderLinkTemplate *template.Template
+var resolverLinkTemplate *template.Template
+var loaderInterfaceTemplate *template.Template
+var memoryLoaderTemplate *template.Template
+var historyMigrationTemplate *template.Template
+var notifyMigrationTemplate *template.Template
+var searchMigrationTemplate *template.Template
 
 var genCmd = cli.Command{
 	Name:  "generate",
@@ -29,6 +41,8 @@ var genCmd = cli.Command{
 	Flags: []cli.Flag{
 		cli.StringFlag{Name: "config, c", Usage: "the config filename"},
 		cli.StringFlag{Name: "folder", Usage: "where to create the project"},
+		cli.BoolFlag{Name: "check", Usage: "exit with an error if any generated files are out of date, without writing anything"},
+		cli.BoolFlag{Name: "diff", Usage: "print a diff of changes to generated files, without writing anything"},
 	},
 	Action: func(ctx *cli.Context) {
 		if len(ctx.String("folder")) > 0 {
@@ -44,109 +58,395 @@ var genCmd = cli.Command{
 			exit(err)
 		}
 
-		// Load config.yaml
-		config, err := readConfig(filePath(ctx, "config.yaml"))
+		// Load config.yaml, from the project folder changed to above
+		config, err := readConfig("config.yaml")
 		if err != nil {
 			exit(err)
 		}
 
 		log.Printf("Config:\n%+v", config)
 
-		generateGnorm(config)
+		err = loadTemplates(config)
+		if err != nil {
+			exit(err)
+		}
 
-		var tasks []Task
-		tasks = append(tasks, Task{Folder: "loader", Build: loaderBuild})
-		tasks = append(tasks, Task{Folder: "loader", Build: postgresBuild})
-		tasks = append(tasks, Task{Folder: "models", Build: modelsBuild})
-		tasks = append(tasks, Task{Folder: "resolvers", Build: resolverBuild})
-		generateFiles(ctx, config, tasks)
+		mode := modeWrite
+		switch {
+		case ctx.Bool("diff"):
+			mode = modeDiff
+		case ctx.Bool("check"):
+			mode = modeCheck
+		}
+
+		// Every stage runs in a scratch copy of the project, so that nothing is
+		// written unless all of them succeed
+		changed, err := generateProject(config, ctx.String("config"), mode)
+		if err != nil {
+			exit(err)
+		}
 
-		// Recreate GraphQL Code
-		_ = generateGQL(ctx)
+		if mode != modeWrite {
+			if changed {
+				exit(fmt.Errorf("Generated files are out of date.  Run 'estack generate' to update them"))
+			}
+			log.Printf("Generated files are up to date")
+		}
 	},
 }
 
-func generateGnorm(config Config) {
-	env := environ.Values{
-		Args:   []string{"gen"},
-		Stderr: os.Stderr,
-		Stdout: os.Stdout,
-		Stdin:  os.Stdin,
+// generateProject Runs every stage of generation in a scratch copy of the
+// project in the working directory: the model migrations, gnorm, estack's
+// own templates and gqlgen.  The copy is then compared with the project, or
+// written over it, depending on mode.  Returns true if anything differs
+func generateProject(config Config, gqlgenConfig string, mode generateMode) (bool, error) {
+	root, err := os.Getwd()
+	if err != nil {
+		return false, err
 	}
 
-	// Delete any existing gnorm files so there are no legacy ones around
-	runCommand("rm -rf gnorm")
-	if !config.Generate.ProtectGnorm {
-		copyTemplateFolder("templates", "templates")
+	if filepath.IsAbs(gqlgenConfig) {
+		rel, err := filepath.Rel(root, gqlgenConfig)
+		if err != nil || strings.HasPrefix(rel, "..") {
+			return false, fmt.Errorf("gqlgen config %s must be inside the project", gqlgenConfig)
+		}
+		gqlgenConfig = rel
 	}
 
-	gcli.ParseAndRun(env)
+	paths, err := generatedPaths(config, gqlgenConfig)
+	if err != nil {
+		return false, err
+	}
 
-	runCommand(fmt.Sprintf("goimports -w %s", "gnorm/."))
+	// The copy is kept beside the project where possible, so that relative
+	// replace directives in go.mod, and a template folder outside the
+	// project, still resolve
+	scratch, err := ioutil.TempDir(filepath.Dir(root), ".estack-generate-")
+	if err != nil {
+		scratch, err = ioutil.TempDir("", "estack-generate-")
+	}
+	if err != nil {
+		return false, err
+	}
+	defer os.RemoveAll(scratch)
 
-	//copyTemplate("gnorm/db.go", "gnorm/db.go")
-	copyTemplate("gnorm/where.go", "gnorm/where.go")
+	err = copyProject(root, scratch, paths)
+	if err != nil {
+		return false, err
+	}
 
+	err = inFolder(scratch, func() error {
+		return runGenerate(config, gqlgenConfig)
+	})
+	if err != nil {
+		return false, err
+	}
+
+	return syncProject(scratch, root, paths, mode)
 }
 
-// copyTemplate Copies files from the template folder relative to *this* file
-// to the destination
-func copyTemplate(source string, destination string) {
-	log.Printf("Copying from %s to %s", source, destination)
-	_, filename, _, ok := runtime.Caller(0)
-	if !ok {
-		panic("No caller information")
+// gqlgenFiles The parts of gqlgen's config naming files that generation reads
+// or writes
+type gqlgenFiles struct {
+	Schema   interface{} `yaml:"schema"` // A filename, or a list of them
+	Exec     struct{ Filename string }
+	Model    struct{ Filename string }
+	Resolver struct{ Filename string }
+	Models   map[string]struct {
+		Model interface{} // A type, or a list of them
 	}
+}
 
-	input, err := ioutil.ReadFile(path.Dir(filename) + "/static/" + source)
-	if err != nil {
-		panic(err)
+// generatedPaths Returns the files and folders, relative to the project
+// root, that generation reads or writes.  Only these are copied for
+// generation, and only files under them are updated or deleted afterwards,
+// so the rest of the project is never touched
+func generatedPaths(config Config, gqlgenConfig string) ([]string, error) {
+	paths := []string{
+		"go.mod",
+		"go.sum",
+		"config.yaml",
+		"gnorm.toml",
+		"schema.graphql",
+		"gnorm",
+		"loader",
+		"models",
+		"resolvers",
+		"authorisation",
+		"graph",
+		"templates",
+		config.Migrate.Folder,
+		config.Generate.TemplateDir,
 	}
 
-	err = ioutil.WriteFile(destination, input, 0644)
+	gqlgenConfigs := []string{gqlgenConfig}
+	if len(gqlgenConfig) == 0 {
+		gqlgenConfigs = []string{".gqlgen.yml", "gqlgen.yml", "gqlgen.yaml"}
+	}
+
+	for _, name := range gqlgenConfigs {
+		input, err := ioutil.ReadFile(name)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		paths = append(paths, name)
+
+		var files gqlgenFiles
+		err = yaml.Unmarshal(input, &files)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", name, err)
+		}
+
+		// Generated files are replaced as a whole folder, so that files
+		// gqlgen no longer writes are deleted too
+		for _, f := range []string{files.Exec.Filename, files.Model.Filename, files.Resolver.Filename} {
+			if len(f) > 0 {
+				paths = append(paths, filepath.Dir(f))
+			}
+		}
+
+		paths = append(paths, stringList(files.Schema)...)
+
+		// gqlgen loads the packages models are bound to
+		for _, m := range files.Models {
+			for _, t := range stringList(m.Model) {
+				if i := strings.LastIndex(t, "."); i > 0 {
+					paths = append(paths, projectPackage(config, t[:i]))
+				}
+			}
+		}
+		break
+	}
+
+	paths, err := projectPaths(paths)
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
+
+	return importedPackages(config, paths)
 }
 
-// copyTemplateFolder copies each file in the specified folder using
-// copyTemplate
-func copyTemplateFolder(source string, destination string) {
-	_, filename, _, ok := runtime.Caller(0)
-	if !ok {
-		panic("No caller information")
+// stringList Returns v, a YAML value holding either a string or a list of
+// them, as a list
+func stringList(v interface{}) []string {
+	switch v := v.(type) {
+	case string:
+		return []string{v}
+	case []interface{}:
+		var list []string
+		for _, s := range v {
+			list = append(list, fmt.Sprint(s))
+		}
+		return list
 	}
 
-	files, err := ioutil.ReadDir(path.Dir(filename) + "/static/" + source)
+	return nil
+}
+
+// projectPackage Returns the folder of the package with the import path pkg,
+// or an empty string if it isn't part of the project
+func projectPackage(config Config, pkg string) string {
+	if len(config.PackageName) == 0 || !strings.HasPrefix(pkg, config.PackageName+"/") {
+		return ""
+	}
+
+	return filepath.FromSlash(strings.TrimPrefix(pkg, config.PackageName+"/"))
+}
+
+// importedPackages Returns paths along with the folders of the project's
+// packages imported by the Go files under them, and by those packages in
+// turn, since they are needed to load the generated code
+func importedPackages(config Config, paths []string) ([]string, error) {
+	seen := make(map[string]bool)
+	for _, p := range paths {
+		seen[p] = true
+	}
+
+	for queue := paths; len(queue) > 0; {
+		var found []string
+
+		err := walkProject(".", queue, func(rel string, info os.FileInfo) error {
+			if info.IsDir() || filepath.Ext(rel) != ".go" {
+				return nil
+			}
+
+			f, err := parser.ParseFile(token.NewFileSet(), rel, nil, parser.ImportsOnly)
+			if err != nil {
+				// Left for the go tools to report
+				return nil
+			}
 
+			for _, i := range f.Imports {
+				pkg, _ := strconv.Unquote(i.Path.Value)
+				if dir := projectPackage(config, pkg); len(dir) > 0 && !seen[dir] {
+					seen[dir] = true
+					found = append(found, dir)
+				}
+			}
+
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		paths = append(paths, found...)
+		queue = found
+	}
+
+	return projectPaths(paths)
+}
+
+// projectPaths Returns paths cleaned and sorted, without duplicates, those
+// inside another of the paths, or those outside the project, which are read
+// where they are
+func projectPaths(paths []string) ([]string, error) {
+	root, err := os.Getwd()
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
 
-	for _, f := range files {
-		if !f.IsDir() {
-			copyTemplate(source+"/"+f.Name(), destination+"/"+f.Name())
+	var cleaned []string
+	for _, p := range paths {
+		if len(p) == 0 {
+			continue
 		}
+
+		if filepath.IsAbs(p) {
+			p, err = filepath.Rel(root, p)
+			if err != nil {
+				continue
+			}
+		}
+
+		p = filepath.Clean(p)
+		if p == "." || p == ".." || strings.HasPrefix(p, ".."+string(filepath.Separator)) {
+			continue
+		}
+		cleaned = append(cleaned, p)
+	}
+	sort.Strings(cleaned)
+
+	var result []string
+	for _, p := range cleaned {
+		if len(result) > 0 {
+			last := result[len(result)-1]
+			if p == last || strings.HasPrefix(p, last+string(filepath.Separator)) {
+				continue
+			}
+		}
+		result = append(result, p)
 	}
 
+	return result, nil
 }
 
-var authorisationModels = []string{
-	"Address",
-	"Brand",
-	"Client",
-	"Invoice",
-	"Person",
-	"Transaction",
+// runGenerate Runs every stage of generation in the working directory,
+// stopping at the first that fails
+func runGenerate(config Config, gqlgenConfig string) error {
+	err := createModelMigrations(config.Migrate.Folder, config)
+	if err != nil {
+		return err
+	}
+
+	err = generateGnorm(config, true)
+	if err != nil {
+		return err
+	}
+
+	err = generateFiles(".", config, generateTasks(config))
+	if err != nil {
+		return err
+	}
+
+	_, err = runGQLGen(gqlgenConfig)
+	return err
 }
 
-// Link Used for auto-generating links between particular items
-type Link struct {
-	Model1 string
-	Model2 string
+// inFolder Runs fn with folder as the working directory, as gnorm and gqlgen
+// read and write their files relative to it
+func inFolder(folder string, fn func() error) error {
+	wd, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+
+	err = os.Chdir(folder)
+	if err != nil {
+		return err
+	}
+	defer os.Chdir(wd)
+
+	return fn()
 }
 
-var links = []Link{}
+func generateGnorm(config Config, copyTemplates bool) error {
+	env := environ.Values{
+		Args:   []string{"gen"},
+		Stderr: os.Stderr,
+		Stdout: os.Stdout,
+		Stdin:  os.Stdin,
+	}
+
+	// Delete any existing gnorm files so there are no legacy ones around
+	err := os.RemoveAll("gnorm")
+	if err != nil {
+		return err
+	}
+
+	if copyTemplates && !config.Generate.ProtectGnorm {
+		err = copyGnormTemplates(config)
+		if err != nil {
+			return err
+		}
+	}
+
+	if code := gcli.ParseAndRun(env); code != 0 {
+		return fmt.Errorf("gnorm exited with code %d", code)
+	}
+
+	err = formatFolder("gnorm")
+	if err != nil {
+		return err
+	}
+
+	//copyTemplate("gnorm/db.go", "gnorm/db.go")
+	return copyTemplate("gnorm/where.go", "gnorm/where.go")
+}
+
+// copyGnormTemplates Copies the gnorm templates into templates/, using the
+// version for the configured database where one exists
+func copyGnormTemplates(config Config) error {
+	files, err := listTemplates("templates")
+	if err != nil {
+		return err
+	}
+
+	for _, f := range files {
+		err = copyTemplate(config.flavourTemplate("templates/"+f), "templates/"+f)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// copyTemplate Copies a static file, or the project's override of it, to the
+// destination
+func copyTemplate(source string, destination string) error {
+	log.Printf("Copying from %s to %s", source, destination)
+
+	input, err := readTemplate(source)
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(destination, input, 0644)
+}
 
 var templateFuncs = map[string]interface{}{
 	"camel":        kace.Camel,
@@ -166,6 +466,7 @@ var templateFuncs = map[string]interface{}{
 	"kebabUpper":   kace.KebabUpper,
 	"lastIndex":    strings.LastIndex,
 	"lastIndexAny": strings.LastIndexAny,
+	"makeSlice":    makeSlice,
 	"pascal":       kace.Pascal,
 	"repeat":       strings.Repeat,
 	"replace":      strings.Replace,
@@ -191,130 +492,558 @@ func concat(vals ...string) string {
 	return strings.Join(vals, "")
 }
 
-// Task We go through a few folders, deleting generated files and running the template
+func makeSlice(vals ...interface{}) []interface{} {
+	return vals
+}
+
+// generateTasks Returns the tasks that render estack's own templates
+func generateTasks(config Config) []Task {
+	var tasks []Task
+	tasks = append(tasks, Task{Folder: "loader", Build: loaderBuild})
+	tasks = append(tasks, Task{Folder: "loader", Build: postgresBuild})
+	tasks = append(tasks, Task{Folder: "loader", Build: loaderInterfaceBuild})
+	tasks = append(tasks, Task{Folder: "models", Build: modelsBuild})
+	tasks = append(tasks, Task{Folder: "resolvers", Build: resolverBuild})
+	tasks = append(tasks, Task{Folder: "resolvers", Build: linkResolverBuild})
+	tasks = append(tasks, Task{Folder: "authorisation", Build: authorisationBuild})
+
+	return tasks
+}
+
+// Task We go through a few folders, rendering the generated files for each
 type Task struct {
 	Folder string
-	Build  func(config Config, folder string) error
+	Build  func(config Config, folder string) ([]generatedFile, error)
+}
+
+// generatedFile A rendered file, held in memory until it is written or
+// compared against the working tree
+type generatedFile struct {
+	Name     string
+	Contents []byte
 }
 
-func generateFiles(ctx *cli.Context, config Config, tasks []Task) {
-	// Set up the tasks:
+// generateMode Whether generated files are written, or only compared
+type generateMode int
+
+const (
+	modeWrite generateMode = iota // Write changed files and delete stale ones
+	modeCheck                     // Report which files would change
+	modeDiff                      // Print a unified diff of what would change
+)
+
+// generateFiles Renders all tasks into memory, then writes them under root.
+// Previously generated gen_*.go files that are no longer produced are stale,
+// and deleted.  Files are only rewritten when their contents change.  If any
+// task fails, nothing is written and the errors from every task are returned
+func generateFiles(root string, config Config, tasks []Task) error {
+	var files []generatedFile
+	var folders []string
+	var errs generateErrors
+	seen := make(map[string]bool)
 
 	for _, t := range tasks {
-		path := filePath(ctx, t.Folder)
-		// Delete ALL previously generated files
-		cmd := exec.Command("/bin/sh", "-c", fmt.Sprintf("rm %s/gen_*.go", path))
-		err := cmd.Run()
+		folder := filepath.Join(root, t.Folder)
+		if !seen[folder] {
+			seen[folder] = true
+			folders = append(folders, folder)
+		}
+
+		built, err := t.Build(config, folder)
+		errs.add(err)
+		files = append(files, built...)
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+
+	produced := make(map[string]bool)
+
+	for _, f := range files {
+		name := filepath.Clean(f.Name)
+		produced[name] = true
+
+		existing, err := ioutil.ReadFile(name)
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+
+		if err == nil && bytes.Equal(existing, f.Contents) {
+			continue
+		}
+
+		// Folders such as authorisation may not exist yet
+		err = os.MkdirAll(filepath.Dir(name), 0755)
 		if err != nil {
-			log.Printf("Failed to delete existing files in %s with '%s', but continuing...", path, err)
+			return err
 		}
 
-		die(t.Build(config, path))
+		err = ioutil.WriteFile(name, f.Contents, 0644)
+		if err != nil {
+			return err
+		}
 	}
-}
 
-func die(err error) {
-	if err != nil {
-		log.Fatal(err)
+	// Any previously generated files not produced this time are stale:
+	for _, folder := range folders {
+		matches, err := filepath.Glob(filepath.Join(folder, "gen_*.go"))
+		if err != nil {
+			return err
+		}
+
+		for _, m := range matches {
+			if produced[filepath.Clean(m)] {
+				continue
+			}
+
+			err = os.Remove(m)
+			if err != nil {
+				return err
+			}
+		}
 	}
+
+	return nil
 }
 
-func authorisationBuild(folder string) error {
-	fileName := "gen_models.go"
-	if len(folder) > 0 {
-		fileName = folder + "/" + fileName
-	}
-	f, err := os.Create(fileName)
+// copyProject Copies paths, the files and folders generation uses, from the
+// project at root to dest
+func copyProject(root string, dest string, paths []string) error {
+	return walkProject(root, paths, func(rel string, info os.FileInfo) error {
+		target := filepath.Join(dest, rel)
+		if info.IsDir() {
+			return os.MkdirAll(target, info.Mode().Perm())
+		}
+
+		err := os.MkdirAll(filepath.Dir(target), 0755)
+		if err != nil {
+			return err
+		}
+
+		input, err := ioutil.ReadFile(filepath.Join(root, rel))
+		if err != nil {
+			return err
+		}
+
+		return ioutil.WriteFile(target, input, info.Mode().Perm())
+	})
+}
+
+// syncProject Compares the files under paths in scratch, a copy of the
+// project that has been generated into, with those under root, reporting,
+// printing or making the changes depending on mode.  Files under paths that
+// are missing from scratch, such as stale generated files, are deleted.
+// Returns true if anything differs
+func syncProject(scratch string, root string, paths []string, mode generateMode) (bool, error) {
+	changed := false
+
+	err := walkProject(scratch, paths, func(rel string, info os.FileInfo) error {
+		if info.IsDir() {
+			return nil
+		}
+
+		generated, err := ioutil.ReadFile(filepath.Join(scratch, rel))
+		if err != nil {
+			return err
+		}
+
+		name := filepath.Join(root, rel)
+		existing, err := ioutil.ReadFile(name)
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+
+		if err == nil && bytes.Equal(existing, generated) {
+			return nil
+		}
+		changed = true
+
+		switch mode {
+		case modeWrite:
+			err = os.MkdirAll(filepath.Dir(name), 0755)
+			if err != nil {
+				return err
+			}
+
+			return ioutil.WriteFile(name, generated, info.Mode().Perm())
+		case modeCheck:
+			if existing == nil {
+				log.Printf("%s would be created", rel)
+			} else {
+				log.Printf("%s is out of date", rel)
+			}
+		case modeDiff:
+			from := rel
+			if existing == nil {
+				from = "/dev/null"
+			}
+			fmt.Print(unifiedDiff(from, rel, existing, generated))
+		}
+
+		return nil
+	})
 	if err != nil {
-		return err
+		return changed, err
 	}
 
-	err = authorisationTemplate.Execute(f, struct {
-		Timestamp time.Time
-		Models    []string
-	}{
-		Timestamp: time.Now(),
-		Models:    authorisationModels,
+	// Folders emptied by deleting their files are removed afterwards, deepest
+	// first
+	var folders []string
+
+	err = walkProject(root, paths, func(rel string, info os.FileInfo) error {
+		_, err := os.Lstat(filepath.Join(scratch, rel))
+		if err == nil || !os.IsNotExist(err) {
+			return err
+		}
+
+		if info.IsDir() {
+			folders = append(folders, rel)
+			return nil
+		}
+		changed = true
+
+		switch mode {
+		case modeWrite:
+			return os.Remove(filepath.Join(root, rel))
+		case modeCheck:
+			log.Printf("%s is stale and would be deleted", rel)
+		case modeDiff:
+			existing, err := ioutil.ReadFile(filepath.Join(root, rel))
+			if err != nil {
+				return err
+			}
+			fmt.Print(unifiedDiff(rel, "/dev/null", existing, nil))
+		}
+
+		return nil
 	})
-	f.Close()
+	if err != nil || mode != modeWrite {
+		return changed, err
+	}
 
-	if err != nil {
-		return err
+	for i := len(folders) - 1; i >= 0; i-- {
+		folder := filepath.Join(root, folders[i])
+
+		// Symlinks and other files that aren't compared are left in place
+		entries, err := ioutil.ReadDir(folder)
+		if err != nil || len(entries) > 0 {
+			continue
+		}
+
+		err = os.Remove(folder)
+		if err != nil {
+			return changed, err
+		}
+	}
+
+	return changed, nil
+}
+
+// walkProject Calls fn with the path relative to root of each folder and
+// regular file under paths in the project, in lexical order.  Paths that
+// don't exist are skipped, as are symlinks and other special files
+func walkProject(root string, paths []string, fn func(rel string, info os.FileInfo) error) error {
+	for _, p := range paths {
+		_, err := os.Lstat(filepath.Join(root, p))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		err = filepath.Walk(filepath.Join(root, p), func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+
+			rel, err := filepath.Rel(root, path)
+			if err != nil {
+				return err
+			}
+
+			if !info.IsDir() && !info.Mode().IsRegular() {
+				return nil
+			}
+
+			return fn(rel, info)
+		})
+		if err != nil {
+			return err
+		}
 	}
 
-	return goImports(fileName)
+	return nil
 }
 
-func modelsBuild(config Config, folder string) error {
-	fileName := "filter.go"
+// renderFile Executes the template, then formats the result and fixes its
+// imports.  Errors name the template and line responsible
+func renderFile(t *template.Template, data interface{}, folder string, name string) (generatedFile, error) {
+	fileName := name
 	if len(folder) > 0 {
 		fileName = folder + "/" + fileName
 	}
-	f, err := os.Create(fileName)
+
+	var buf bytes.Buffer
+	err := t.Execute(&buf, data)
 	if err != nil {
-		return err
+		return generatedFile{}, fmt.Errorf("%s: %s", fileName, err)
+	}
+
+	contents, err := formatGo(fileName, buf.Bytes())
+	if err != nil {
+		return generatedFile{}, sourceErrors(fileName, t.Name(), buf.Bytes(), err)
+	}
+
+	return generatedFile{Name: fileName, Contents: contents}, nil
+}
+
+// linkResolverBuild Renders mutations and list resolvers for links
+func linkResolverBuild(config Config, folder string) ([]generatedFile, error) {
+	links, imports, err := linkBuildData(config)
+	if err != nil || len(links) == 0 {
+		// Errors are reported by postgresBuild
+		return nil, nil
 	}
 
-	err = filterTemplate.Execute(f, struct {
-		Timestamp time.Time
-		Config    Config
+	f, err := renderFile(resolverLinkTemplate, struct {
+		Config  Config
+		Links   []linkData
+		Imports []string
 	}{
-		Timestamp: time.Now(),
-		Config:    config,
-	})
-	f.Close()
+		Config:  config,
+		Links:   links,
+		Imports: imports,
+	}, folder, "gen_links.go")
 
-	if err != nil {
-		return err
+	return []generatedFile{f}, err
+}
+
+// linkData A link, along with everything needed about the models at either
+// end to render it
+type linkData struct {
+	Model1   PostgresGenerate
+	Model2   PostgresGenerate
+	Schema   string
+	Table    string
+	Column1  string
+	Column2  string
+	Package  string // gnorm package for the join table
+	Package1 string // gnorm package for model1's table
+	Package2 string // gnorm package for model2's table
+	Field1   string // Go field in the join table row referencing model1
+	Field2   string // Go field in the join table row referencing model2
+	Plural1  string
+	Plural2  string
+	Policy   string
+}
+
+// linkBuildData Resolves each link in the config against the postgres models
+// it references.  Also returns the model packages that need importing
+func linkBuildData(config Config) ([]linkData, []string, error) {
+	var links []linkData
+	var imports []string
+	var errs generateErrors
+	seen := make(map[string]bool)
+
+	pluralName := func(override string, name string) string {
+		if len(override) > 0 {
+			return override
+		}
+		for _, r := range config.Generate.Resolvers {
+			if r.SingularModelName == name && len(r.PluralModelName) > 0 {
+				return r.PluralModelName
+			}
+		}
+		return plural(name)
 	}
 
-	return goImports(fileName)
+	for _, l := range config.Links {
+		m1, ok1 := postgresModel(config, l.Model1)
+		m2, ok2 := postgresModel(config, l.Model2)
+		if !ok1 || !ok2 || len(l.Table) == 0 {
+			errs.add(fmt.Errorf("links: %s to %s must name a join table, and both models must be listed under generate.postgres", l.Model1, l.Model2))
+			continue
+		}
+
+		d := linkData{
+			Model1:   m1,
+			Model2:   m2,
+			Schema:   config.Generate.SchemaName,
+			Table:    l.Table,
+			Column1:  l.Column1,
+			Column2:  l.Column2,
+			Package:  strings.ToLower(kace.Pascal(l.Table)),
+			Package1: strings.ToLower(m1.ModelName),
+			Package2: strings.ToLower(m2.ModelName),
+			Plural1:  pluralName(l.Plural1, m1.ModelName),
+			Plural2:  pluralName(l.Plural2, m2.ModelName),
+			Policy:   l.Policy,
+		}
+
+		if len(d.Column1) == 0 {
+			d.Column1 = kace.Snake(m1.ModelName) + "_id"
+		}
+		if len(d.Column2) == 0 {
+			d.Column2 = kace.Snake(m2.ModelName) + "_id"
+		}
+		if len(d.Policy) == 0 {
+			d.Policy = "data.api.link"
+		}
+		d.Field1 = kace.Pascal(d.Column1)
+		d.Field2 = kace.Pascal(d.Column2)
+
+		for _, i := range []string{
+			m1.ModelPackage,
+			m2.ModelPackage,
+			fmt.Sprintf("%s/gnorm/%s/%s", config.PackageName, config.Generate.SchemaName, d.Package),
+			fmt.Sprintf("%s/gnorm/%s/%s", config.PackageName, config.Generate.SchemaName, d.Package1),
+			fmt.Sprintf("%s/gnorm/%s/%s", config.PackageName, config.Generate.SchemaName, d.Package2),
+		} {
+			if !seen[i] {
+				seen[i] = true
+				imports = append(imports, i)
+			}
+		}
+
+		links = append(links, d)
+	}
+
+	return links, imports, errs.err()
 }
 
-func loaderBuild(config Config, folder string) error {
-	fileName := "generated.go"
-	if len(folder) > 0 {
-		fileName = folder + "/" + fileName
+// authorisationBuild Renders authorisation functions for each model listed
+// under authorisation in config.yaml
+func authorisationBuild(config Config, folder string) ([]generatedFile, error) {
+	a := config.Authorisation
+	if len(a.Models) == 0 {
+		return nil, nil
 	}
-	f, err := os.Create(fileName)
-	if err != nil {
-		return err
+
+	inputBuilder := a.InputBuilder
+	if len(inputBuilder) == 0 {
+		inputBuilder = "defaultInput"
 	}
 
-	err = loaderTemplate.Execute(f, struct {
-		Timestamp time.Time
-		Config    Config
+	var files []generatedFile
+	var errs generateErrors
+
+	f, err := renderFile(authorisationSharedTemplate, struct {
+		Config Config
 	}{
-		Timestamp: time.Now(),
-		Config:    config,
-	})
-	f.Close()
+		Config: config,
+	}, folder, "gen_authorisation.go")
+	errs.add(err)
+	files = append(files, f)
+
+	for _, m := range a.Models {
+		var model *PostgresGenerate
+		for i, p := range config.Generate.Postgres {
+			if p.ModelName == m.ModelName {
+				model = &config.Generate.Postgres[i]
+				break
+			}
+		}
 
-	if err != nil {
-		return err
+		if model == nil {
+			errs.add(fmt.Errorf("authorisation: model '%s' must also be listed under generate.postgres", m.ModelName))
+			continue
+		}
+
+		policy := m.Policy
+		if len(policy) == 0 {
+			policy = fmt.Sprintf("%s.%s.allow", a.QueryPolicy, kace.Camel(m.ModelName))
+		}
+
+		f, err := renderFile(authorisationTemplate, struct {
+			Config         Config
+			ModelName      string
+			ModelStruct    string
+			ModelPackage   string
+			PrimaryKeyType string
+			Policy         string
+			InputBuilder   string
+		}{
+			Config:         config,
+			ModelName:      model.ModelName,
+			ModelStruct:    model.ModelStruct,
+			ModelPackage:   model.ModelPackage,
+			PrimaryKeyType: model.PrimaryKeyType,
+			Policy:         policy,
+			InputBuilder:   inputBuilder,
+		}, folder, fmt.Sprintf("gen_%s.go", kace.Snake(m.ModelName)))
+
+		errs.add(err)
+		files = append(files, f)
 	}
 
-	return goImports(fileName)
+	return files, errs.err()
+}
+
+func modelsBuild(config Config, folder string) ([]generatedFile, error) {
+	f, err := renderFile(filterTemplate, struct {
+		Config Config
+	}{
+		Config: config,
+	}, folder, "filter.go")
+
+	return []generatedFile{f}, err
 }
 
-func postgresBuild(config Config, folder string) error {
+func loaderBuild(config Config, folder string) ([]generatedFile, error) {
+	f, err := renderFile(loaderTemplate, struct {
+		Config        Config
+		NotifyChannel string
+	}{
+		Config:        config,
+		NotifyChannel: notifyChannel,
+	}, folder, "generated.go")
+
+	return []generatedFile{f}, err
+}
+
+func postgresBuild(config Config, folder string) ([]generatedFile, error) {
+	var files []generatedFile
+	var errs generateErrors
+
 	// Core models
 	for _, b := range config.Generate.Postgres {
-		fileName := fmt.Sprintf("gen_%s.go", kace.Snake(b.ModelName))
+		if len(b.DeletedAt) > 0 && len(b.Cascade) > 0 {
+			errs.add(fmt.Errorf("postgres: %s can't cascade deletes, as deletedAt keeps the row when it's deleted", b.ModelName))
+			continue
+		}
 
-		if len(folder) > 0 {
-			fileName = folder + "/" + fileName
+		versionType, err := modelVersionType(b)
+		if err != nil {
+			errs.add(err)
+			continue
 		}
 
-		f, err := os.Create(fileName)
+		nested, err := nestedChildren(config, b)
+		if err != nil {
+			errs.add(err)
+			continue
+		}
 
+		err = checkSearch(config, b)
 		if err != nil {
-			return err
+			errs.add(err)
+			continue
 		}
 
-		err = postgresTemplate.Execute(f, struct {
+		// Nested children are updated with their own packages' changes
+		var imports []string
+		seen := map[string]bool{strings.ToLower(b.ModelName): true}
+		for _, n := range nested {
+			if !seen[n.Package] {
+				seen[n.Package] = true
+				imports = append(imports, fmt.Sprintf("%s/gnorm/%s/%s", config.PackageName, config.Generate.SchemaName, n.Package))
+			}
+		}
+
+		f, err := renderFile(postgresTemplate, struct {
 			Config         Config
-			Timestamp      time.Time
 			ModelName      string
 			ModelStruct    string
 			ModelPackage   string
@@ -322,9 +1051,17 @@ func postgresBuild(config Config, folder string) error {
 			PK             string
 			PrimaryKeyType string
 			Create         bool
+			Delete         bool
+			Cascade        []CascadeDelete
+			DeletedAt      string
+			Version        string
+			VersionType    string
+			Audit          bool
+			HistoryTable   string
+			Nested         []childData
+			Imports        []string
 		}{
 			Config:         config,
-			Timestamp:      time.Now(),
 			ModelName:      b.ModelName,
 			ModelStruct:    b.ModelStruct,
 			ModelPackage:   b.ModelPackage,
@@ -332,74 +1069,166 @@ func postgresBuild(config Config, folder string) error {
 			PK:             b.PK,
 			PrimaryKeyType: b.PrimaryKeyType,
 			Create:         b.Create,
-		})
-		f.Close()
-
-		if err != nil {
-			return err
-		}
-
-		err = goImports(fileName)
-		if err != nil {
-			return err
-		}
+			Delete:         b.Delete,
+			Cascade:        cascadeDeletes(b),
+			DeletedAt:      b.DeletedAt,
+			Version:        b.Version,
+			VersionType:    versionType,
+			Audit:          b.Audit,
+			HistoryTable:   config.Generate.SchemaName + "." + historyTable(b),
+			Nested:         nested,
+			Imports:        imports,
+		}, folder, fmt.Sprintf("gen_%s.go", kace.Snake(b.ModelName)))
+
+		errs.add(err)
+		files = append(files, f)
 	}
 
 	// Links:
-	fileName := fmt.Sprintf("gen_links.go")
-
-	if len(folder) > 0 {
-		fileName = folder + "/" + fileName
+	links, imports, err := linkBuildData(config)
+	if err != nil || len(links) == 0 {
+		errs.add(err)
+		return files, errs.err()
 	}
 
-	f, err := os.Create(fileName)
+	f, err := renderFile(loaderLinkTemplate, struct {
+		Config  Config
+		Links   []linkData
+		Imports []string
+	}{
+		Config:  config,
+		Links:   links,
+		Imports: imports,
+	}, folder, "gen_links.go")
+	errs.add(err)
 
-	if err != nil {
-		return err
+	return append(files, f), errs.err()
+}
+
+// loaderModel A model listed under generate.postgres, along with the gnorm
+// package for its table
+type loaderModel struct {
+	PostgresGenerate
+	Package string
+	Nested  []childData
+}
+
+// childData A nested child of a model, along with the child's own model
+type childData struct {
+	NestedChild
+	loaderModel
+	Path string // The child's name in the paths of field errors
+}
+
+// loaderInterfaceBuild Renders the loader Interface, covering the functions
+// generated for each model and link, and the MemoryLoader implementing it
+func loaderInterfaceBuild(config Config, folder string) ([]generatedFile, error) {
+	// Errors are reported by postgresBuild
+	links, imports, _ := linkBuildData(config)
+
+	seen := make(map[string]bool)
+	for _, i := range imports {
+		seen[i] = true
 	}
 
-	err = linkTemplate.Execute(f, struct {
-		Config    Config
-		Timestamp time.Time
-		Models    []Link
-	}{
-		Config:    config,
-		Timestamp: time.Now(),
-		Models:    links,
-	})
-	f.Close()
+	var models []loaderModel
+	for _, p := range config.Generate.Postgres {
+		m := loaderModel{PostgresGenerate: p, Package: strings.ToLower(p.ModelName)}
+		m.VersionType, _ = modelVersionType(p)
+		m.Nested, _ = nestedChildren(config, p)
+		models = append(models, m)
+
+		for _, i := range []string{
+			p.ModelPackage,
+			fmt.Sprintf("%s/gnorm/%s/%s", config.PackageName, config.Generate.SchemaName, m.Package),
+		} {
+			if !seen[i] {
+				seen[i] = true
+				imports = append(imports, i)
+			}
+		}
+	}
 
-	if err != nil {
-		return err
+	data := struct {
+		Config  Config
+		Models  []loaderModel
+		Links   []linkData
+		Imports []string
+	}{
+		Config:  config,
+		Models:  models,
+		Links:   links,
+		Imports: imports,
 	}
 
-	err = goImports(fileName)
-	if err != nil {
-		return err
+	var files []generatedFile
+	var errs generateErrors
+	for _, t := range []struct {
+		Template *template.Template
+		Name     string
+	}{
+		{loaderInterfaceTemplate, "gen_interface.go"},
+		{memoryLoaderTemplate, "gen_memory.go"},
+	} {
+		f, err := renderFile(t.Template, data, folder, t.Name)
+		errs.add(err)
+		files = append(files, f)
 	}
 
-	return nil
+	return files, errs.err()
 }
 
-func resolverBuild(config Config, folder string) error {
-	log.Printf("Creating resolver files")
+func resolverBuild(config Config, folder string) ([]generatedFile, error) {
+	var files []generatedFile
+	var errs generateErrors
+
 	for _, b := range config.Generate.Resolvers {
-		log.Printf("...%s", b.SingularModelName)
-		fileName := fmt.Sprintf("gen_%s.go", kace.Snake(b.SingularModelName))
+		m, ok := postgresModel(config, b.SingularModelName)
+		if b.Delete && !m.Delete {
+			errs.add(fmt.Errorf("resolvers: delete for %s needs delete to be set for the model under generate.postgres", b.SingularModelName))
+			continue
+		}
+		if b.Restore && len(m.DeletedAt) == 0 {
+			errs.add(fmt.Errorf("resolvers: restore for %s needs deletedAt to be set for the model under generate.postgres", b.SingularModelName))
+			continue
+		}
+		if b.Subscribe && (!ok || !b.Query) {
+			errs.add(fmt.Errorf("resolvers: subscribe for %s needs query, and the model under generate.postgres", b.SingularModelName))
+			continue
+		}
+		if b.OrderBy && !b.Query {
+			errs.add(fmt.Errorf("resolvers: orderBy for %s needs query", b.SingularModelName))
+			continue
+		}
+		if b.Where && !b.Query {
+			errs.add(fmt.Errorf("resolvers: where for %s needs query", b.SingularModelName))
+			continue
+		}
+		if b.Subscribe && config.Generate.Database != databasePostgres {
+			errs.add(fmt.Errorf("resolvers: subscribe for %s needs postgres, which sends the changes", b.SingularModelName))
+			continue
+		}
+
+		// Errors are reported by postgresBuild
+		versionType, _ := modelVersionType(m)
 
-		if len(folder) > 0 {
-			fileName = folder + "/" + fileName
+		deletePolicy := b.DeletePolicy
+		if len(deletePolicy) == 0 {
+			deletePolicy = fmt.Sprintf("data.api.delete.%s.allow", kace.Camel(b.SingularModelName))
 		}
 
-		f, err := os.Create(fileName)
+		historyPolicy := b.HistoryPolicy
+		if len(historyPolicy) == 0 {
+			historyPolicy = fmt.Sprintf("data.api.history.%s.allow", kace.Camel(b.SingularModelName))
+		}
 
-		if err != nil {
-			return err
+		subscribePolicy := b.SubscribePolicy
+		if len(subscribePolicy) == 0 {
+			subscribePolicy = fmt.Sprintf("data.api.subscribe.%s.allow", kace.Camel(b.SingularModelName))
 		}
 
-		err = resolverTemplate.Execute(f, struct {
+		f, err := renderFile(resolverTemplate, struct {
 			Config          Config
-			Timestamp       time.Time
 			ModelName       string
 			PluralModelName string
 			PrimaryKey      string
@@ -408,9 +1237,25 @@ func resolverBuild(config Config, folder string) error {
 			Update          bool
 			PrepareCreate   bool
 			Query           bool
+			OrderBy         bool
+			Where           bool
+			Search          bool
+			SearchColumn    string
+			SearchConfig    string
+			Delete          bool
+			DeletePolicy    string
+			Restore         bool
+			Version         string
+			VersionType     string
+			Audit           bool
+			HistoryPolicy   string
+			Subscribe       bool
+			SubscribePolicy string
+			Table           string
+			PKColumn        string
+			Package         string
 		}{
 			Config:          config,
-			Timestamp:       time.Now(),
 			ModelName:       b.SingularModelName,
 			PluralModelName: b.PluralModelName,
 			PrimaryKey:      b.PrimaryKey,
@@ -419,128 +1264,133 @@ func resolverBuild(config Config, folder string) error {
 			Update:          b.Update,
 			PrepareCreate:   b.PrepareCreate,
 			Query:           b.Query,
-		})
-		f.Close()
+			OrderBy:         b.OrderBy,
+			Where:           b.Where,
+			Search:          b.Query && len(m.Search) > 0,
+			SearchColumn:    searchColumn,
+			SearchConfig:    searchConfig(m),
+			Delete:          b.Delete,
+			DeletePolicy:    deletePolicy,
+			Restore:         b.Restore,
+			Version:         m.Version,
+			VersionType:     versionType,
+			Audit:           m.Audit,
+			HistoryPolicy:   historyPolicy,
+			Subscribe:       b.Subscribe,
+			SubscribePolicy: subscribePolicy,
+			Table:           kace.Snake(b.SingularModelName),
+			PKColumn:        kace.Snake(m.PK),
+			Package:         strings.ToLower(b.SingularModelName),
+		}, folder, fmt.Sprintf("gen_%s.go", kace.Snake(b.SingularModelName)))
+
+		errs.add(err)
+		files = append(files, f)
+	}
 
-		if err != nil {
-			return err
-		}
+	return files, errs.err()
+}
 
-		err = goImports(fileName)
-		if err != nil {
-			return err
+// cascadeDeletes Returns the child tables to delete from along with the
+// model, with default columns filled in
+func cascadeDeletes(p PostgresGenerate) []CascadeDelete {
+	var cascade []CascadeDelete
+	for _, c := range p.Cascade {
+		if len(c.Column) == 0 {
+			c.Column = kace.Snake(p.ModelName) + "_id"
 		}
+		cascade = append(cascade, c)
 	}
 
-	return nil
-}
-
-func goImports(fileName string) error {
-	// Run goimports against newly created file:
-	cmd := exec.Command("/bin/sh", "-c", fmt.Sprintf("goimports -w %s", fileName))
-	return cmd.Run()
+	return cascade
 }
 
-var authorisationTemplate = template.Must(template.New("").Funcs(templateFuncs).Parse(
-	`// Code generated by go generate; DO NOT EDIT.
-// This file was generated by robots
-package authorisation
+// nestedChildren Returns the children of p, with their columns defaulted and
+// their models filled in, after checking that they can be created and
+// updated along with it
+func nestedChildren(config Config, p PostgresGenerate) ([]childData, error) {
+	var children []childData
+	for _, c := range p.Children {
+		m, ok := postgresModel(config, c.Model)
+		switch {
+		case !p.Create:
+			return nil, fmt.Errorf("postgres: children for %s need create to be set, as they are written along with it", p.ModelName)
+		case len(c.Field) == 0:
+			return nil, fmt.Errorf("postgres: a child of %s has no field", p.ModelName)
+		case !ok || !m.Create:
+			return nil, fmt.Errorf("postgres: child %s of %s needs %s under generate.postgres, with create set", c.Field, p.ModelName, c.Model)
+		}
 
-import (
-	"{{.Config.PackageName}}/loader"
-	"{{.Config.PackageName}}/models"
-	"{{.Config.PackageName}}/gnorm/dbl"
-	"github.com/episub/estack/opa"
-	opentracing "github.com/opentracing/opentracing-go"
-)
+		if len(c.Column) == 0 {
+			c.Column = kace.Snake(c.Field) + "_id"
+		}
 
-{{ range $x, $c :=  .Models -}}
-// {{.}}Input Create {{.}}Input as a variable so that it can be overridden in the init function if desired
-var {{.}}Input = func(ctx context.Context, input map[string]interface{}, i models.{{.}}) error {
-	input["{{camel .}}"] = i
-	input["user"] = GetUserFromContext(ctx)
+		d := childData{
+			NestedChild: c,
+			loaderModel: loaderModel{PostgresGenerate: m, Package: strings.ToLower(m.ModelName)},
+			Path:        kace.Snake(c.Field),
+		}
+		d.VersionType, _ = modelVersionType(m)
+		children = append(children, d)
+	}
 
-	return nil
+	return children, nil
 }
 
-// {{.}}Fetch Fetches {{.}} and authorises
-func {{.}}Fetch(ctx context.Context, id string) (*models.{{.}}, error) {
-	span, ctx := opentracing.StartSpanFromContext(ctx, "{{.}}Fetch")
-	defer span.Finish()
-	o, err := loader.Get{{.}}(ctx, id)
-	if err != nil {
-		return nil, err
+// postgresModel Returns the named model from those listed under
+// generate.postgres
+func postgresModel(config Config, name string) (PostgresGenerate, bool) {
+	for _, p := range config.Generate.Postgres {
+		if p.ModelName == name {
+			return p, true
+		}
 	}
-	return {{.}}(ctx, o)
+
+	return PostgresGenerate{}, false
 }
 
-// {{.}} Authorises {{.}}
-func {{.}}(ctx context.Context, i models.{{.}}) (*models.{{.}}, error) {
-	input := make(map[string]interface{})
-	err := AddDefaultPayload(ctx, input)
-	if err != nil {
-		return nil, err
+// modelVersionType Returns the Go type of the model's version column, with the
+// default filled in, after checking that versioned updates can be generated
+// for it.  Returns an empty string if the model has no version column
+func modelVersionType(p PostgresGenerate) (string, error) {
+	if len(p.Version) == 0 {
+		return "", nil
 	}
-	{{.}}Input(ctx, input, i)
-
-	allowed, err := opa.Authorised(ctx, getAuthString("query", "{{camel .}}", "allow"), input)
 
-	if err != nil {
-		return nil, err
+	if !p.Create {
+		return "", fmt.Errorf("postgres: version for %s needs create to be set, as updates are only generated along with create", p.ModelName)
 	}
 
-	if !allowed {
-		return nil, permissionDeniedError("{{camel .}}")
+	switch p.VersionType {
+	case "":
+		return versionInt, nil
+	case versionInt, versionTime:
+		return p.VersionType, nil
 	}
 
-	return &i, nil
+	return "", fmt.Errorf("postgres: unsupported versionType '%s' for %s.  Use %s or %s", p.VersionType, p.ModelName, versionInt, versionTime)
 }
-{{ end }}
-`))
-
-var linkTemplate = template.Must(template.New("").Funcs(templateFuncs).Parse(
-	`// Code generated by go generate; DO NOT EDIT.
-// This file was generated by robots
-package loader
-
-import (
-	"{{.Config.PackageName}}/models"
-	"{{.Config.PackageName}}/loader"
-	"{{.Config.PackageName}}/gnorm"
-	"{{.Config.PackageName}}/gnorm/dbl"
-	"github.com/episub/estack/validate"
-	"github.com/episub/estack/opa"
-	opentracing "github.com/opentracing/opentracing-go"
-)
-
-{{ range $x, $c :=  .Models -}}
-{{$m1 := $c.Model1}}
-{{$m2 := $c.Model2}}
-{{$full := concat $m1 $m2}}
-// Link{{$c.Model1}}{{$c.Model2}} Links '{{$c.Model1}}' to {{$c.Model2}}'
-func (l *PostgresLoader) Link{{$c.Model1}}{{$c.Model2}}(ctx context.Context, {{camel $m1}}ID string, {{camel $m2}}ID string, link bool) (bool, error) {
-	if link {
-		var clink dbl.{{$m1}}{{$m2}}
-
-		clink.{{$c.Model1}}ID{{$c.Model1}} = {{camel $c.Model1}}ID
-		clink.{{$c.Model2}}ID{{$c.Model2}} = {{camel $c.Model2}}ID
 
-		_, err := dbl.Upsert{{$m1}}{{$m2}}(ctx, l.pool, clink)
-
-		// Sanitise our output, and log errors if needed:
-		err = sanitiseError(err)
+// checkSearch Checks that the model's search column can be generated, which
+// needs postgres, and columns and a text search configuration that can be
+// written into its migration as they are
+func checkSearch(config Config, p PostgresGenerate) error {
+	if len(p.Search) == 0 {
+		return nil
+	}
 
-		return (err == nil), err
+	if config.Generate.Database != databasePostgres {
+		return fmt.Errorf("postgres: search for %s needs postgres, which provides tsvector", p.ModelName)
 	}
 
-	// !link, therefore delete any such connection:
-	res, err := l.pool.Exec("DELETE FROM dbl.{{snake $full}} WHERE {{snake $c.Model1}}_id_{{snake $c.Model1}}=$1 AND {{snake $c.Model2}}_id_{{snake $c.Model2}}=$2", {{camel $m1}}ID, {{camel $m2}}ID)
+	for _, c := range p.Search {
+		if !safeTableRx.MatchString(c) {
+			return fmt.Errorf("postgres: invalid search column '%s' for %s", c, p.ModelName)
+		}
+	}
 
-	err = sanitiseError(err)
-	if err == nil && res.RowsAffected() == 0 {
-		err = fmt.Errorf("No such link exists")
+	if !safeTableRx.MatchString(searchConfig(p)) {
+		return fmt.Errorf("postgres: invalid searchConfig '%s' for %s", p.SearchConfig, p.ModelName)
 	}
-	return (err == nil), err
+
+	return nil
 }
-{{end}}
-`))
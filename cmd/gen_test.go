@@ -0,0 +1,510 @@
+package cmd
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoaderInterfaceBuild(t *testing.T) {
+	config := defaultConfig()
+	config.PackageName = "example.com/app"
+	config.Generate.Database = databaseMySQL
+	config.Generate.Postgres = []PostgresGenerate{
+		{ModelName: "Todo", ModelStruct: "todo.Row", ModelPackage: "example.com/app/gnorm/estack/todo", PmName: "Todo", PK: "TodoID", PrimaryKeyType: "int", Create: true},
+		{ModelName: "Tag", ModelStruct: "tag.Row", ModelPackage: "example.com/app/gnorm/estack/tag", PmName: "Tag", PK: "TagID", PrimaryKeyType: "uuid.UUID"},
+	}
+	config.Links = []Link{{Model1: "Todo", Model2: "Tag", Table: "todo_tag"}}
+
+	err := loadTemplates(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	files, err := loaderInterfaceBuild(config, "loader")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	contents := make(map[string]string)
+	for _, f := range files {
+		contents[f.Name] = string(f.Contents)
+	}
+
+	expected := map[string][]string{
+		"loader/gen_interface.go": {
+			"createTodo(ctx context.Context, db gnorm.DB, i map[string]interface{}) (todo.Row, error)",
+			"UpdateTodo(ctx context.Context, id int, c todo.Changes) error",
+			"GetAllTodo(ctx context.Context, filter models.Filter) ([]todo.Row, []string, models.PageInfo, int, error)",
+			"GetTagTodos(ctx context.Context, tagID uuid.UUID) ([]todo.Row, error)",
+			"WithTx(ctx context.Context, opts *TxOptions, fn func(ctx context.Context) error) error",
+			"var _ Interface = (*MySQLLoader)(nil)",
+		},
+		"loader/gen_memory.go": {
+			"func (l *MemoryLoader) UpdateTodo(",
+			"func (l *MemoryLoader) LinkTodoTag(",
+			"todoTagLinks map[int]map[uuid.UUID]bool",
+			"func (l *MemoryLoader) WithTx(",
+			"l.todoTagLinks = todoTagLinks",
+		},
+	}
+
+	for name, wants := range expected {
+		c, ok := contents[name]
+		if !ok {
+			t.Errorf("Expected %s to be generated", name)
+			continue
+		}
+
+		for _, w := range wants {
+			if !strings.Contains(c, w) {
+				t.Errorf("Expected %s to contain '%s'", name, w)
+			}
+		}
+	}
+
+	if strings.Contains(contents["loader/gen_memory.go"], "UpdateTag(") {
+		t.Errorf("Expected no UpdateTag, as Tag doesn't set create")
+	}
+}
+
+func TestResolverNeedsModel(t *testing.T) {
+	tests := []struct {
+		Resolver ResolverGenerate
+		Expected string
+	}{
+		{ResolverGenerate{SingularModelName: "Todo", Delete: true}, "delete for Todo"},
+		{ResolverGenerate{SingularModelName: "Todo", Restore: true}, "restore for Todo"},
+		{ResolverGenerate{SingularModelName: "Todo", Subscribe: true}, "subscribe for Todo"},
+	}
+
+	for _, test := range tests {
+		config := defaultConfig()
+		config.Generate.Postgres = []PostgresGenerate{{ModelName: "Todo"}}
+		config.Generate.Resolvers = []ResolverGenerate{test.Resolver}
+
+		err := loadTemplates(config)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = resolverBuild(config, "resolvers")
+		if err == nil || !strings.Contains(err.Error(), test.Expected) {
+			t.Errorf("Expected an error containing '%s' as the Todo model doesn't allow it, but had %v", test.Expected, err)
+		}
+	}
+}
+
+func TestSoftDeleteCascade(t *testing.T) {
+	config := defaultConfig()
+	config.Generate.Postgres = []PostgresGenerate{{
+		ModelName: "Todo",
+		Delete:    true,
+		DeletedAt: "deleted_at",
+		Cascade:   []CascadeDelete{{Table: "comment"}},
+	}}
+
+	err := loadTemplates(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = postgresBuild(config, "loader")
+	if err == nil || !strings.Contains(err.Error(), "can't cascade") {
+		t.Errorf("Expected an error as soft deleted models can't cascade, but had %v", err)
+	}
+}
+
+func TestCascadeDeletes(t *testing.T) {
+	p := PostgresGenerate{
+		ModelName: "TodoList",
+		Cascade:   []CascadeDelete{{Table: "todo"}, {Table: "share", Column: "list_id"}},
+	}
+
+	expected := []CascadeDelete{{Table: "todo", Column: "todo_list_id"}, {Table: "share", Column: "list_id"}}
+	cascade := cascadeDeletes(p)
+
+	if len(cascade) != len(expected) {
+		t.Fatalf("Expected %d cascades, but had %d", len(expected), len(cascade))
+	}
+
+	for i, e := range expected {
+		if cascade[i] != e {
+			t.Errorf("Expected %+v, but had %+v", e, cascade[i])
+		}
+	}
+}
+
+func TestModelVersionType(t *testing.T) {
+	tests := []struct {
+		Model    PostgresGenerate
+		Expected string
+		Error    bool
+	}{
+		{PostgresGenerate{ModelName: "Todo", Create: true}, "", false},
+		{PostgresGenerate{ModelName: "Todo", Create: true, Version: "version"}, "int", false},
+		{PostgresGenerate{ModelName: "Todo", Create: true, Version: "updated_at", VersionType: "time.Time"}, "time.Time", false},
+		{PostgresGenerate{ModelName: "Todo", Create: true, Version: "version", VersionType: "string"}, "", true},
+		{PostgresGenerate{ModelName: "Todo", Version: "version"}, "", true},
+	}
+
+	for _, test := range tests {
+		versionType, err := modelVersionType(test.Model)
+		if (err != nil) != test.Error {
+			t.Errorf("Expected error to be %t for %+v, but had %v", test.Error, test.Model, err)
+		}
+
+		if versionType != test.Expected {
+			t.Errorf("Expected '%s' for %+v, but had '%s'", test.Expected, test.Model, versionType)
+		}
+	}
+}
+
+func TestCheckSearch(t *testing.T) {
+	tests := []struct {
+		Database string
+		Model    PostgresGenerate
+		Error    bool
+	}{
+		{databaseMySQL, PostgresGenerate{ModelName: "Todo"}, false},
+		{databasePostgres, PostgresGenerate{ModelName: "Todo", Search: []string{"title", "content"}}, false},
+		{databasePostgres, PostgresGenerate{ModelName: "Todo", Search: []string{"title"}, SearchConfig: "simple"}, false},
+		{databasePostgres, PostgresGenerate{ModelName: "Todo", Search: []string{"title || content"}}, true},
+		{databasePostgres, PostgresGenerate{ModelName: "Todo", Search: []string{"title"}, SearchConfig: "english'"}, true},
+		{databaseMySQL, PostgresGenerate{ModelName: "Todo", Search: []string{"title"}}, true},
+	}
+
+	for _, test := range tests {
+		config := defaultConfig()
+		config.Generate.Database = test.Database
+
+		err := checkSearch(config, test.Model)
+		if (err != nil) != test.Error {
+			t.Errorf("Expected error to be %t for %+v with %s, but had %v", test.Error, test.Model, test.Database, err)
+		}
+	}
+}
+
+func TestNestedChildren(t *testing.T) {
+	config := defaultConfig()
+	config.Generate.Postgres = []PostgresGenerate{
+		{ModelName: "Person", PK: "PersonID", Create: true, Version: "version"},
+		{ModelName: "Address", PK: "AddressID"},
+	}
+
+	tests := []struct {
+		Child    NestedChild
+		Create   bool
+		Expected string
+		Error    bool
+	}{
+		{NestedChild{Field: "person", Model: "Person"}, true, "person_id", false},
+		{NestedChild{Field: "billingPerson", Model: "Person"}, true, "billing_person_id", false},
+		{NestedChild{Field: "person", Model: "Person", Column: "contact_id"}, true, "contact_id", false},
+		{NestedChild{Field: "person", Model: "Person"}, false, "", true},
+		{NestedChild{Model: "Person"}, true, "", true},
+		{NestedChild{Field: "address", Model: "Address"}, true, "", true},
+		{NestedChild{Field: "phone", Model: "Phone"}, true, "", true},
+	}
+
+	for _, test := range tests {
+		p := PostgresGenerate{ModelName: "Client", Create: test.Create, Children: []NestedChild{test.Child}}
+
+		children, err := nestedChildren(config, p)
+		if (err != nil) != test.Error {
+			t.Errorf("Expected error to be %t for %+v, but had %v", test.Error, test.Child, err)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+
+		c := children[0]
+		if c.Column != test.Expected || c.PK != "PersonID" || c.VersionType != versionInt {
+			t.Errorf("Expected column %s of Person, versioned by int, for %+v, but had %+v", test.Expected, test.Child, c)
+		}
+	}
+}
+
+// renderConfig Returns a postgres config with a Todo and a Tag model, with
+// resolvers that query and update them
+func renderConfig() Config {
+	config := defaultConfig()
+	config.PackageName = "example.com/app"
+	config.Generate.SchemaName = "public"
+	config.Generate.Postgres = []PostgresGenerate{
+		{ModelName: "Todo", ModelStruct: "todo.Row", ModelPackage: "example.com/app/gnorm/public/todo", PmName: "Todo", PK: "TodoID", PrimaryKeyType: "int", Create: true},
+		{ModelName: "Tag", ModelStruct: "tag.Row", ModelPackage: "example.com/app/gnorm/public/tag", PmName: "Tag", PK: "TagID", PrimaryKeyType: "uuid.UUID", Create: true},
+	}
+	config.Generate.Resolvers = []ResolverGenerate{
+		{SingularModelName: "Todo", PluralModelName: "Todos", PrimaryKey: "TodoID", PrimaryKeyType: "int", Create: true, Update: true, Query: true},
+		{SingularModelName: "Tag", PluralModelName: "Tags", PrimaryKey: "TagID", PrimaryKeyType: "uuid.UUID", Create: true, Update: true, Query: true},
+	}
+	config.Links = []Link{{Model1: "Todo", Model2: "Tag", Table: "todo_tag"}}
+
+	return config
+}
+
+var renderCases = []struct {
+	Name   string
+	Config func(c *Config)
+}{
+	{"plain", func(c *Config) {}},
+	{"delete and cascade", func(c *Config) {
+		c.Generate.Postgres[0].Delete = true
+		c.Generate.Postgres[0].Cascade = []CascadeDelete{{Table: "comment"}, {Table: "note", Column: "parent_id"}}
+		c.Generate.Resolvers[0].Delete = true
+	}},
+	{"soft delete", func(c *Config) {
+		c.Generate.Postgres[1].Delete = true
+		c.Generate.Postgres[1].DeletedAt = "deleted_at"
+		c.Generate.Resolvers[1].Delete = true
+		c.Generate.Resolvers[1].Restore = true
+		c.Generate.Resolvers[1].Where = true
+	}},
+	{"version", func(c *Config) {
+		c.Generate.Postgres[0].Version = "version"
+		c.Generate.Postgres[1].Version = "updated_at"
+		c.Generate.Postgres[1].VersionType = versionTime
+	}},
+	{"audit", func(c *Config) {
+		c.Generate.Postgres[1].Audit = true
+	}},
+	{"subscriptions", func(c *Config) {
+		c.Generate.Resolvers[0].Subscribe = true
+	}},
+	{"where and subscribe", func(c *Config) {
+		c.Generate.Postgres[1].Delete = true
+		c.Generate.Postgres[1].DeletedAt = "deleted_at"
+		for i := range c.Generate.Resolvers {
+			c.Generate.Resolvers[i].Where = true
+			c.Generate.Resolvers[i].Subscribe = true
+		}
+		c.Generate.Resolvers[1].Delete = true
+		c.Generate.Resolvers[1].Restore = true
+	}},
+	{"int64 key", func(c *Config) {
+		c.Generate.Postgres[0].PrimaryKeyType = "int64"
+		c.Generate.Postgres[0].Delete = true
+		c.Generate.Resolvers[0].PrimaryKeyType = "int64"
+		c.Generate.Resolvers[0].Delete = true
+	}},
+	{"nested children", func(c *Config) {
+		c.Generate.Postgres[0].Children = []NestedChild{{Field: "tag", Model: "Tag"}}
+	}},
+	{"where, orderBy and search", func(c *Config) {
+		c.Generate.Postgres[0].Search = []string{"title", "content"}
+		c.Generate.Postgres[1].Search = []string{"name"}
+		c.Generate.Postgres[1].SearchConfig = "simple"
+		c.Generate.Resolvers[0].Where = true
+		c.Generate.Resolvers[0].OrderBy = true
+		c.Generate.Resolvers[1].OrderBy = true
+	}},
+}
+
+// TestRender Renders the postgres loader and resolver templates, which
+// formats the output, so that each feature produces valid Go
+func TestRender(t *testing.T) {
+	builds := []struct {
+		Template string
+		Folder   string
+		Build    func(config Config, folder string) ([]generatedFile, error)
+		Files    []string
+	}{
+		{"loader/generated.gotmpl", "loader", loaderBuild, []string{"loader/generated.go"}},
+		{"loader/gen.gotmpl", "loader", postgresBuild, []string{"loader/gen_todo.go", "loader/gen_tag.go"}},
+		{"resolvers/gen.gotmpl", "resolvers", resolverBuild, []string{"resolvers/gen_todo.go", "resolvers/gen_tag.go"}},
+	}
+
+	// The features are rendered on their own, and then all together:
+	cases := append(renderCases, struct {
+		Name   string
+		Config func(c *Config)
+	}{"everything", func(c *Config) {
+		for _, r := range renderCases {
+			r.Config(c)
+		}
+	}})
+
+	for _, c := range cases {
+		config := renderConfig()
+		c.Config(&config)
+
+		err := loadTemplates(config)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, b := range builds {
+			files, err := b.Build(config, b.Folder)
+			if err != nil {
+				t.Errorf("%s: %s: %s", c.Name, b.Template, err)
+				continue
+			}
+
+			rendered := make(map[string]bool)
+			for _, f := range files {
+				rendered[f.Name] = len(f.Contents) > 0
+			}
+
+			for _, name := range b.Files {
+				if !rendered[name] {
+					t.Errorf("%s: expected %s to render %s", c.Name, b.Template, name)
+				}
+			}
+		}
+	}
+}
+
+func TestSyncProject(t *testing.T) {
+	root, err := ioutil.TempDir("", "project")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+
+	scratch, err := ioutil.TempDir("", "scratch")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(scratch)
+
+	files := map[string]string{
+		"loader/same.go":          "package same\n",
+		"loader/changed.go":       "package old\n",
+		"loader/stale/gen_old.go": "package stale\n",
+		"vendor/lib/lib.go":       "package lib\n",
+	}
+	paths := []string{"loader", "resolvers"}
+	for name, contents := range files {
+		err = os.MkdirAll(filepath.Join(root, filepath.Dir(name)), 0755)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = ioutil.WriteFile(filepath.Join(root, name), []byte(contents), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	err = copyProject(root, scratch, paths)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = os.Stat(filepath.Join(scratch, "vendor")); !os.IsNotExist(err) {
+		t.Errorf("Expected vendor to be left out of the copy, but had %v", err)
+	}
+
+	// Generating changes one file, adds another and removes a folder:
+	ioutil.WriteFile(filepath.Join(scratch, "loader", "changed.go"), []byte("package changed\n"), 0644)
+	os.MkdirAll(filepath.Join(scratch, "resolvers"), 0755)
+	ioutil.WriteFile(filepath.Join(scratch, "resolvers", "gen_new.go"), []byte("package created\n"), 0644)
+	os.RemoveAll(filepath.Join(scratch, "loader", "stale"))
+
+	changed, err := syncProject(scratch, root, paths, modeCheck)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !changed {
+		t.Errorf("Expected check to report changes")
+	}
+
+	if _, err = os.Stat(filepath.Join(root, "loader", "stale", "gen_old.go")); err != nil {
+		t.Errorf("Expected check to leave the project as it was, but had %v", err)
+	}
+
+	changed, err = syncProject(scratch, root, paths, modeWrite)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !changed {
+		t.Errorf("Expected write to report changes")
+	}
+
+	// Files outside paths are left alone, though missing from the copy:
+	expected := map[string]string{
+		"loader/same.go":       "package same\n",
+		"loader/changed.go":    "package changed\n",
+		"resolvers/gen_new.go": "package created\n",
+		"vendor/lib/lib.go":    "package lib\n",
+	}
+	for name, contents := range expected {
+		b, err := ioutil.ReadFile(filepath.Join(root, name))
+		if err != nil || string(b) != contents {
+			t.Errorf("Expected %s to contain %q, but had %q (%v)", name, contents, b, err)
+		}
+	}
+
+	if _, err = os.Stat(filepath.Join(root, "loader", "stale")); !os.IsNotExist(err) {
+		t.Errorf("Expected the stale folder to be removed, but had %v", err)
+	}
+
+	changed, err = syncProject(scratch, root, paths, modeCheck)
+	if err != nil || changed {
+		t.Errorf("Expected no changes once written, but had %t (%v)", changed, err)
+	}
+}
+
+func TestGeneratedPaths(t *testing.T) {
+	root, err := ioutil.TempDir("", "project")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+
+	files := map[string]string{
+		"gqlgen.yml":        "schema:\n- api/schema.graphql\nexec:\n  filename: api/gen/exec.go\nmodel:\n  filename: models/models_gen.go\nmodels:\n  Time:\n    model: example.com/app/scalars.Time\n",
+		"resolvers/a.go":    "package resolvers\n\nimport \"example.com/app/opa\"\n",
+		"opa/opa.go":        "package opa\n\nimport (\n\t\"fmt\"\n\n\t\"example.com/app/policy\"\n)\n",
+		"policy/policy.go":  "package policy\n",
+		"scalars/time.go":   "package scalars\n",
+		"vendor/lib/lib.go": "package lib\n",
+	}
+	for name, contents := range files {
+		err = os.MkdirAll(filepath.Join(root, filepath.Dir(name)), 0755)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = ioutil.WriteFile(filepath.Join(root, name), []byte(contents), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	config := defaultConfig()
+	config.PackageName = "example.com/app"
+	config.Generate.TemplateDir = "../shared"
+
+	var paths []string
+	err = inFolder(root, func() error {
+		paths, err = generatedPaths(config, "")
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	listed := make(map[string]bool)
+	for _, p := range paths {
+		listed[filepath.ToSlash(p)] = true
+	}
+
+	for _, p := range []string{"gqlgen.yml", "api/schema.graphql", "api/gen", "models", "resolvers", "opa", "policy", "scalars", config.Migrate.Folder} {
+		if !listed[p] {
+			t.Errorf("Expected %s to be listed, but had %v", p, paths)
+		}
+	}
+
+	for _, p := range []string{"vendor", "../shared", "."} {
+		if listed[p] {
+			t.Errorf("Expected %s not to be listed, but had %v", p, paths)
+		}
+	}
+}
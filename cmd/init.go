@@ -1,10 +1,9 @@
 package cmd
 
 import (
-	"fmt"
-	"io/ioutil"
 	"log"
 	"os"
+	"path/filepath"
 	"strings"
 
 	"github.com/99designs/gqlgen/api"
@@ -13,73 +12,6 @@ import (
 	"github.com/urfave/cli"
 )
 
-var gqlConfigDefault = `
-schema:
-- schema.graphql
-exec:
-  filename: graph/generated.go
-  package: graph
-model:
-  filename: models/models_gen.go
-  package: models
-resolver:
-  filename: resolvers/resolver.go
-  package: resolvers
-  type: Resolver
-`
-
-var gqlSchemaDefault = `
-# GraphQL schema example
-#
-# https://gqlgen.com/getting-started/
-
-type Todo {
-  id: ID!
-  content: String!
-  done: Boolean!
-  user: User!
-}
-
-type User {
-  id: ID!
-  username: String!
-  admin: Boolean!
-}
-
-type Query {
-  todos: [Todo!]!
-}
-
-input NewTodo {
-  text: String!
-  userId: String!
-}
-
-type Mutation {
-  createTodo(input: NewTodo!): Todo!
-}
-
-enum SortDirection {
-  ASC
-  DESC
-}
-`
-
-var dockerComposeDefault = `
-version: '3'
-services:
-  postgres:
-    image: postgres:9.6
-    ports:
-    - "5432:5432"
-    restart: always
-    environment:
-      POSTGRES_USER: estack
-      POSTGRES_PASSWORD: estack
-    volumes:
-      - ./migrations:/docker-entrypoint-initdb.d
-`
-
 var initCmd = cli.Command{
 	Name:  "init",
 	Usage: "create a new estack project",
@@ -93,8 +25,25 @@ var initCmd = cli.Command{
 		cli.StringFlag{Name: "schema", Usage: "where to write the schema stub to", Value: "schema.graphql"},
 		cli.StringFlag{Name: "server", Usage: "where to write the server stub to", Value: "server/server.go"},
 		cli.StringFlag{Name: "folder", Usage: "where to create the project"},
+		cli.StringFlag{
+			Name:  "template, t",
+			Value: "minimal",
+			Usage: "project to start from: minimal, auth, full, or the path to a folder of files to layer over minimal",
+		},
+		cli.StringFlag{Name: "database", Value: databasePostgres, Usage: "database the project uses: postgres or mysql"},
 	},
 	Action: func(ctx *cli.Context) {
+		// Resolve a template folder before changing to the project folder, so
+		// that relative paths are from where init was run:
+		templateName := ctx.String("template")
+		if _, ok := projectTemplates[templateName]; !ok {
+			abs, err := filepath.Abs(templateName)
+			if err != nil {
+				exit(err)
+			}
+			templateName = abs
+		}
+
 		if len(ctx.String("folder")) > 0 {
 			err := os.Chdir(ctx.String("folder"))
 			if err != nil {
@@ -118,21 +67,35 @@ var initCmd = cli.Command{
 			exit(err)
 		}
 
-		_ = os.Mkdir("migrations", 0755)
+		files, project, err := loadProject(templateName, ctx.String("database"))
+		if err != nil {
+			exit(err)
+		}
+
+		data := projectData{
+			PackageName: packageName,
+			Template:    ctx.String("template"),
+			Database:    ctx.String("database"),
+			Auth:        project.Auth,
+			OPA:         project.OPA,
+		}
+
 		_ = os.Mkdir("static", 0755)
 		_ = os.Mkdir("templates", 0755)
-		_ = os.Mkdir("loader", 0755)
 
-		createFile(ctx, "schema.graphql", gqlSchemaDefault)
-		createFile(ctx, "gqlgen.yml", gqlConfigDefault)
-		createFile(ctx, "docker-compose.yml", dockerComposeDefault)
-		copyTemplate("migrations/001-base.sql", "migrations/001-base.sql")
-		createFileFromTemplate("gnorm.toml", "gnorm.toml")
-		createFileFromTemplate("config.yaml", "config.yaml")
+		err = writeProject(files, data, false)
+		if err != nil {
+			exit(err)
+		}
 
 		generateGQL(ctx)
-		createFileFromTemplate("server.go", "server.go")
-		createFileFromTemplate("loader/init.gotmpl", "loader/init.go")
+
+		// Go files are written once gqlgen has run, as they refer to the
+		// packages it creates:
+		err = writeProject(files, data, true)
+		if err != nil {
+			exit(err)
+		}
 	},
 }
 
@@ -150,59 +113,38 @@ func filePath(ctx *cli.Context, filename string) string {
 	return final
 }
 
-func createFile(ctx *cli.Context, filename string, contents string) {
-	filename = filePath(ctx, filename)
-	_, err := os.Stat(filename)
-	if !os.IsNotExist(err) {
-		return
-	}
-
-	err = ioutil.WriteFile(filename, []byte(strings.TrimSpace(contents)), 0644)
-	if err != nil {
-		fmt.Fprintln(os.Stderr, fmt.Sprintf("unable to write file %s: %s", filename, err))
-		os.Exit(1)
-	}
-}
-
-// createFileFromTemplate input is the filename (under cmd/static) to use as template, and output  isi the file name to create
-func createFileFromTemplate(input string, output string) {
-	t := loadTemplateFromFile(input)
-
-	f, err := os.Create(output)
+// GenerateGQL Generates gql stuff
+func generateGQL(ctx *cli.Context) *config.Config {
+	cfg, err := runGQLGen(ctx.String("config"))
 	if err != nil {
-		panic(err)
+		exit(err)
 	}
-	err = t.Execute(f, packageName)
 
-	if err != nil {
-		panic(err)
-	}
+	return cfg
 }
 
-// GenerateGQL Generates gql stuff
-func generateGQL(ctx *cli.Context) *config.Config {
+// runGQLGen Runs gqlgen using the given config file, or gqlgen's default
+// locations if not provided
+func runGQLGen(configFilename string) (*config.Config, error) {
 	var cfg *config.Config
 	var err error
-	if configFilename := ctx.String("config"); configFilename != "" {
+	if configFilename != "" {
 		cfg, err = config.LoadConfig(configFilename)
 		if err != nil {
-			fmt.Fprintln(os.Stderr, err.Error())
-			os.Exit(1)
+			return nil, err
 		}
 	} else {
 		cfg, err = config.LoadConfigFromDefaultLocations()
 		if os.IsNotExist(errors.Cause(err)) {
 			cfg = config.DefaultConfig()
 		} else if err != nil {
-			fmt.Fprintln(os.Stderr, err.Error())
-			os.Exit(2)
+			return nil, err
 		}
 	}
 
 	if err = api.Generate(cfg); err != nil {
-		fmt.Fprintln(os.Stderr, err.Error())
-		os.Exit(3)
+		return nil, err
 	}
 
-	return cfg
+	return cfg, nil
 }
@@ -0,0 +1,751 @@
+package cmd
+
+import (
+	"bytes"
+	"database/sql"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"text/tabwriter"
+	"text/template"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/codemodus/kace"
+	"github.com/urfave/cli"
+
+	// Registers the "pgx" driver with database/sql
+	_ "github.com/jackc/pgx/stdlib"
+)
+
+// migrationRx Matches paired migration files, e.g., 002-add-invoices.up.sql
+var migrationRx = regexp.MustCompile(`^([0-9]+)-([^.]+)\.(up|down)\.sql$`)
+
+// legacyMigrationRx Matches migrations created before paired files were
+// supported, e.g., 001-base.sql.  These are treated as up-only migrations
+var legacyMigrationRx = regexp.MustCompile(`^([0-9]+)-([^.]+)\.sql$`)
+
+var safeTableRx = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z_0-9]*$`)
+
+var migrateFlags = []cli.Flag{
+	cli.StringFlag{Name: "config, c", Usage: "the config filename"},
+	cli.StringFlag{Name: "folder", Usage: "the project folder"},
+	cli.StringFlag{Name: "conn", Usage: "database connection string.  Defaults to ConnStr in gnorm.toml", EnvVar: "DATABASE_URL"},
+}
+
+var migrateCmd = cli.Command{
+	Name:  "migrate",
+	Usage: "apply, roll back or inspect database migrations",
+	Subcommands: []cli.Command{
+		{
+			Name:      "up",
+			Usage:     "apply all pending migrations, or those up to and including the given version",
+			ArgsUsage: "[version]",
+			Flags:     migrateFlags,
+			Action: func(ctx *cli.Context) {
+				target, err := versionArg(ctx)
+				if err != nil {
+					exit(err)
+				}
+
+				withMigrator(ctx, func(m *migrator) error {
+					return m.up(target)
+				})
+			},
+		},
+		{
+			Name:      "down",
+			Usage:     "roll back the most recently applied migration, or the given number of migrations",
+			ArgsUsage: "[count]",
+			Flags:     migrateFlags,
+			Action: func(ctx *cli.Context) {
+				count := 1
+				if ctx.NArg() > 0 {
+					var err error
+					count, err = strconv.Atoi(ctx.Args().First())
+					if err != nil || count < 1 {
+						exit(fmt.Errorf("Invalid count '%s'", ctx.Args().First()))
+					}
+				}
+
+				withMigrator(ctx, func(m *migrator) error {
+					return m.down(count)
+				})
+			},
+		},
+		{
+			Name:  "redo",
+			Usage: "roll back and re-apply the most recently applied migration",
+			Flags: migrateFlags,
+			Action: func(ctx *cli.Context) {
+				withMigrator(ctx, func(m *migrator) error {
+					return m.redo()
+				})
+			},
+		},
+		{
+			Name:  "status",
+			Usage: "list migrations and whether they have been applied",
+			Flags: migrateFlags,
+			Action: func(ctx *cli.Context) {
+				withMigrator(ctx, func(m *migrator) error {
+					return m.status(os.Stdout)
+				})
+			},
+		},
+		{
+			Name:      "create",
+			Usage:     "create a new pair of up and down migration files",
+			ArgsUsage: "<name>",
+			Flags:     migrateFlags,
+			Action: func(ctx *cli.Context) {
+				if ctx.NArg() != 1 {
+					exit(fmt.Errorf("Usage: estack migrate create <name>"))
+				}
+
+				config := migrateConfig(ctx)
+				err := createMigration(filePath(ctx, config.Migrate.Folder), ctx.Args().First())
+				if err != nil {
+					exit(err)
+				}
+			},
+		},
+	},
+}
+
+// migration A versioned pair of up and down files.  Down may be empty for
+// legacy migrations, in which case it cannot be rolled back
+type migration struct {
+	Version int64
+	Name    string
+	Up      string
+	Down    string
+}
+
+// migrator Applies migrations against the database, recording the applied
+// versions in the configured table
+type migrator struct {
+	db         *sql.DB
+	table      string
+	migrations []migration
+}
+
+// migrateConfig Returns the config for the project, or the defaults when no
+// config.yaml exists yet
+func migrateConfig(ctx *cli.Context) Config {
+	filename := ctx.String("config")
+	if len(filename) == 0 {
+		filename = "config.yaml"
+	}
+
+	config, err := readConfig(filePath(ctx, filename))
+	if os.IsNotExist(err) {
+		return defaultConfig()
+	}
+	if err != nil {
+		exit(err)
+	}
+
+	return config
+}
+
+// withMigrator Connects to the database and runs fn, exiting on any error
+func withMigrator(ctx *cli.Context, fn func(m *migrator) error) {
+	m, err := openMigrator(ctx.String("folder"), ctx.String("conn"), migrateConfig(ctx))
+	if err != nil {
+		exit(err)
+	}
+	defer m.db.Close()
+
+	err = fn(m)
+	if err != nil {
+		exit(err)
+	}
+}
+
+// openMigrator Loads the migrations from the project in folder and connects
+// to the database.  The caller is responsible for closing the migrator's
+// database
+func openMigrator(folder string, conn string, config Config) (*migrator, error) {
+	// The migrations table, placeholders and driver are all PostgreSQL's:
+	if config.Generate.Database != databasePostgres {
+		return nil, fmt.Errorf("estack migrate only supports %s, but generate.database is '%s'.  Apply the migrations with another tool", databasePostgres, config.Generate.Database)
+	}
+
+	migrations, err := loadMigrations(filepath.Join(folder, config.Migrate.Folder))
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err = connectionString(folder, conn)
+	if err != nil {
+		return nil, err
+	}
+
+	db, err := sql.Open("pgx", conn)
+	if err != nil {
+		return nil, err
+	}
+
+	m, err := newMigrator(db, config.Migrate.Table, migrations)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return m, nil
+}
+
+// gnormConfig The parts of gnorm.toml that estack makes use of
+type gnormConfig struct {
+	ConnStr string
+	DBType  string
+}
+
+// connectionString Returns conn if provided on the command line, falling
+// back to the one gnorm uses for the project in folder
+func connectionString(folder string, conn string) (string, error) {
+	if len(conn) > 0 {
+		return conn, nil
+	}
+
+	var gc gnormConfig
+	_, err := toml.DecodeFile(filepath.Join(folder, "gnorm.toml"), &gc)
+	if err != nil {
+		return "", fmt.Errorf("No connection string provided and could not read gnorm.toml: %s", err)
+	}
+
+	if len(gc.ConnStr) == 0 {
+		return "", fmt.Errorf("No connection string provided and no ConnStr in gnorm.toml")
+	}
+
+	return gc.ConnStr, nil
+}
+
+// versionArg Returns the optional version argument, or 0 if not provided
+func versionArg(ctx *cli.Context) (int64, error) {
+	if ctx.NArg() == 0 {
+		return 0, nil
+	}
+
+	v, err := strconv.ParseInt(ctx.Args().First(), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("Invalid version '%s'", ctx.Args().First())
+	}
+
+	return v, nil
+}
+
+// loadMigrations Reads the migrations folder, pairing up and down files by
+// version.  Results are sorted by version
+func loadMigrations(folder string) ([]migration, error) {
+	files, err := ioutil.ReadDir(folder)
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := make(map[int64]*migration)
+
+	for _, f := range files {
+		if f.IsDir() {
+			continue
+		}
+
+		var version, name, direction string
+		if matches := migrationRx.FindStringSubmatch(f.Name()); matches != nil {
+			version, name, direction = matches[1], matches[2], matches[3]
+		} else if matches := legacyMigrationRx.FindStringSubmatch(f.Name()); matches != nil {
+			version, name, direction = matches[1], matches[2], "up"
+		} else {
+			continue
+		}
+
+		v, err := strconv.ParseInt(version, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid migration version in %s: %s", f.Name(), err)
+		}
+
+		m, ok := byVersion[v]
+		if !ok {
+			m = &migration{Version: v, Name: name}
+			byVersion[v] = m
+		}
+
+		if m.Name != name {
+			return nil, fmt.Errorf("Migration version %d is used by both '%s' and '%s'", v, m.Name, name)
+		}
+
+		path := filepath.Join(folder, f.Name())
+		if direction == "up" {
+			if len(m.Up) > 0 {
+				return nil, fmt.Errorf("Migration version %d has more than one up file", v)
+			}
+			m.Up = path
+		} else {
+			m.Down = path
+		}
+	}
+
+	var migrations []migration
+	for _, m := range byVersion {
+		if len(m.Up) == 0 {
+			return nil, fmt.Errorf("Migration %03d-%s has a down file but no up file", m.Version, m.Name)
+		}
+		migrations = append(migrations, *m)
+	}
+
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+
+	return migrations, nil
+}
+
+// createMigration Creates the next pair of migration files in folder
+func createMigration(folder string, name string) error {
+	return writeMigration(folder, name, "", "")
+}
+
+// writeMigration Creates the next pair of migration files in folder, with
+// the given SQL following the header of each
+func writeMigration(folder string, name string, up string, down string) error {
+	name = kace.Kebab(name)
+	if len(name) == 0 {
+		return fmt.Errorf("Migration name cannot be empty")
+	}
+
+	err := os.MkdirAll(folder, 0755)
+	if err != nil {
+		return err
+	}
+
+	migrations, err := loadMigrations(folder)
+	if err != nil {
+		return err
+	}
+
+	var version int64 = 1
+	if len(migrations) > 0 {
+		version = migrations[len(migrations)-1].Version + 1
+	}
+
+	for _, file := range []struct {
+		Direction string
+		SQL       string
+	}{
+		{"up", up},
+		{"down", down},
+	} {
+		fileName := filepath.Join(folder, fmt.Sprintf("%03d-%s.%s.sql", version, name, file.Direction))
+		contents := fmt.Sprintf("-- Migration %03d (%s): %s\n", version, file.Direction, name)
+		if len(file.SQL) > 0 {
+			contents += "\n" + file.SQL
+		}
+
+		err = ioutil.WriteFile(fileName, []byte(contents), 0644)
+		if err != nil {
+			return err
+		}
+
+		log.Printf("Created %s", fileName)
+	}
+
+	return nil
+}
+
+// modelMigration A migration added by generate for a model, rendered from
+// the "up" and "down" templates defined in Template
+type modelMigration struct {
+	Name     string
+	Template *template.Template
+	Data     interface{}
+}
+
+// modelMigrations Returns the migrations needed by the models' settings: a
+// history table for each model with audit set, a search column for each model
+// with search set, and a notify trigger for each model with subscriptions
+func modelMigrations(config Config) []modelMigration {
+	var migrations []modelMigration
+
+	for _, p := range config.Generate.Postgres {
+		if !p.Audit {
+			continue
+		}
+
+		migrations = append(migrations, modelMigration{
+			Name:     kace.Kebab(historyTable(p)),
+			Template: historyMigrationTemplate,
+			Data: struct {
+				Schema  string
+				Table   string
+				History string
+			}{
+				Schema:  config.Generate.SchemaName,
+				Table:   kace.Snake(p.ModelName),
+				History: historyTable(p),
+			},
+		})
+	}
+
+	// Searches and subscriptions need postgres, which postgresBuild and
+	// resolverBuild report
+	if config.Generate.Database != databasePostgres {
+		return migrations
+	}
+
+	for _, p := range config.Generate.Postgres {
+		if len(p.Search) == 0 || checkSearch(config, p) != nil {
+			continue
+		}
+
+		migrations = append(migrations, modelMigration{
+			Name:     kace.Kebab(p.ModelName) + "-search",
+			Template: searchMigrationTemplate,
+			Data: struct {
+				Schema  string
+				Table   string
+				Column  string
+				Config  string
+				Columns []string
+			}{
+				Schema:  config.Generate.SchemaName,
+				Table:   kace.Snake(p.ModelName),
+				Column:  searchColumn,
+				Config:  searchConfig(p),
+				Columns: p.Search,
+			},
+		})
+	}
+
+	for _, r := range config.Generate.Resolvers {
+		p, ok := postgresModel(config, r.SingularModelName)
+		if !r.Subscribe || !ok {
+			continue
+		}
+
+		migrations = append(migrations, modelMigration{
+			Name:     kace.Kebab(p.ModelName) + "-notify",
+			Template: notifyMigrationTemplate,
+			Data: struct {
+				Schema  string
+				Table   string
+				Column  string
+				Channel string
+			}{
+				Schema:  config.Generate.SchemaName,
+				Table:   kace.Snake(p.ModelName),
+				Column:  kace.Snake(p.PK),
+				Channel: notifyChannel,
+			},
+		})
+	}
+
+	return migrations
+}
+
+// createModelMigrations Creates each migration needed by the models'
+// settings, unless there is already a migration by that name, e.g.
+// 004-todo-history.  Once created, a migration is left for the project to
+// edit or apply
+func createModelMigrations(folder string, config Config) error {
+	existing := make(map[string]bool)
+
+	migrations, err := loadMigrations(folder)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	for _, m := range migrations {
+		existing[m.Name] = true
+	}
+
+	for _, m := range modelMigrations(config) {
+		if existing[m.Name] {
+			continue
+		}
+
+		var up, down bytes.Buffer
+		err = m.Template.ExecuteTemplate(&up, "up", m.Data)
+		if err != nil {
+			return err
+		}
+
+		err = m.Template.ExecuteTemplate(&down, "down", m.Data)
+		if err != nil {
+			return err
+		}
+
+		err = writeMigration(folder, m.Name, up.String(), down.String())
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// historyTable Returns the name of the table recording changes to the
+// model's rows
+func historyTable(p PostgresGenerate) string {
+	return kace.Snake(p.ModelName) + "_history"
+}
+
+// notifyChannel Channel the notify triggers send each change on, which the
+// loader listens to for subscriptions
+const notifyChannel = "estack_changes"
+
+// searchColumn Generated column holding the search vector of a model with
+// search set
+const searchColumn = "search_vector"
+
+// searchConfig Returns the text search configuration of the model's search
+// column, with the default filled in
+func searchConfig(p PostgresGenerate) string {
+	if len(p.SearchConfig) == 0 {
+		return "english"
+	}
+
+	return p.SearchConfig
+}
+
+func newMigrator(db *sql.DB, table string, migrations []migration) (*migrator, error) {
+	if !safeTableRx.MatchString(table) {
+		return nil, fmt.Errorf("Invalid migrations table name '%s'", table)
+	}
+
+	m := &migrator{db: db, table: table, migrations: migrations}
+
+	_, err := db.Exec(fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+	version bigint PRIMARY KEY,
+	name text NOT NULL,
+	applied_at timestamptz NOT NULL DEFAULT now()
+)`, table))
+
+	return m, err
+}
+
+// applied Returns the applied versions and when they were applied
+func (m *migrator) applied() (map[int64]time.Time, error) {
+	rows, err := m.db.Query(fmt.Sprintf("SELECT version, applied_at FROM %s", m.table))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := make(map[int64]time.Time)
+	for rows.Next() {
+		var v int64
+		var at time.Time
+		err = rows.Scan(&v, &at)
+		if err != nil {
+			return nil, err
+		}
+		applied[v] = at
+	}
+
+	return applied, rows.Err()
+}
+
+// up Applies pending migrations in order.  If target is more than 0, stops
+// after applying that version
+func (m *migrator) up(target int64) error {
+	applied, err := m.applied()
+	if err != nil {
+		return err
+	}
+
+	count := 0
+	for _, mg := range m.migrations {
+		if target > 0 && mg.Version > target {
+			break
+		}
+
+		if _, ok := applied[mg.Version]; ok {
+			continue
+		}
+
+		ran, err := m.run(mg, true)
+		if err != nil {
+			return err
+		}
+		if ran {
+			count++
+		}
+	}
+
+	log.Printf("Applied %d migration(s)", count)
+
+	return nil
+}
+
+// down Rolls back the last count applied migrations, most recent first
+func (m *migrator) down(count int) error {
+	applied, err := m.applied()
+	if err != nil {
+		return err
+	}
+
+	for i := len(m.migrations) - 1; i >= 0 && count > 0; i-- {
+		mg := m.migrations[i]
+		if _, ok := applied[mg.Version]; !ok {
+			continue
+		}
+
+		_, err = m.run(mg, false)
+		if err != nil {
+			return err
+		}
+		count--
+	}
+
+	return nil
+}
+
+// redo Rolls back the most recently applied migration and applies it again
+func (m *migrator) redo() error {
+	applied, err := m.applied()
+	if err != nil {
+		return err
+	}
+
+	for i := len(m.migrations) - 1; i >= 0; i-- {
+		mg := m.migrations[i]
+		if _, ok := applied[mg.Version]; !ok {
+			continue
+		}
+
+		_, err = m.run(mg, false)
+		if err != nil {
+			return err
+		}
+
+		_, err = m.run(mg, true)
+		return err
+	}
+
+	return fmt.Errorf("No applied migrations to redo")
+}
+
+// status Writes each migration along with when it was applied
+func (m *migrator) status(out io.Writer) error {
+	applied, err := m.applied()
+	if err != nil {
+		return err
+	}
+
+	return writeStatus(out, m.migrations, applied)
+}
+
+// writeStatus Writes a table of migrations along with when they were applied,
+// followed by applied versions that no longer have files
+func writeStatus(out io.Writer, migrations []migration, applied map[int64]time.Time) error {
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "VERSION\tNAME\tAPPLIED")
+
+	known := make(map[int64]bool)
+	for _, mg := range migrations {
+		known[mg.Version] = true
+
+		status := "pending"
+		if at, ok := applied[mg.Version]; ok {
+			status = at.Format(time.RFC3339)
+		}
+		fmt.Fprintf(w, "%03d\t%s\t%s\n", mg.Version, mg.Name, status)
+	}
+
+	// Versions applied to the database that no longer have files, in order:
+	var missing []int64
+	for v := range applied {
+		if !known[v] {
+			missing = append(missing, v)
+		}
+	}
+	sort.Slice(missing, func(i, j int) bool { return missing[i] < missing[j] })
+
+	for _, v := range missing {
+		fmt.Fprintf(w, "%03d\t(missing file)\t%s\n", v, applied[v].Format(time.RFC3339))
+	}
+
+	return w.Flush()
+}
+
+// run Applies or rolls back a single migration in a transaction, recording
+// the change in the migrations table.  The table is locked for the
+// transaction, so that migrate run at the same time elsewhere, such as by
+// another deploy, waits for it.  Returns false if another run had already
+// made the change by the time the lock was taken
+func (m *migrator) run(mg migration, up bool) (bool, error) {
+	file := mg.Up
+	if !up {
+		file = mg.Down
+		if len(file) == 0 {
+			return false, fmt.Errorf("Migration %03d-%s has no down file and cannot be rolled back", mg.Version, mg.Name)
+		}
+	}
+
+	contents, err := ioutil.ReadFile(file)
+	if err != nil {
+		return false, err
+	}
+
+	tx, err := m.db.Begin()
+	if err != nil {
+		return false, err
+	}
+
+	// SHARE ROW EXCLUSIVE conflicts with itself, but not with reads such as
+	// status
+	_, err = tx.Exec(fmt.Sprintf("LOCK TABLE %s IN SHARE ROW EXCLUSIVE MODE", m.table))
+	if err != nil {
+		tx.Rollback()
+		return false, err
+	}
+
+	var applied bool
+	err = tx.QueryRow(fmt.Sprintf("SELECT EXISTS (SELECT 1 FROM %s WHERE version = $1)", m.table), mg.Version).Scan(&applied)
+	if err != nil {
+		tx.Rollback()
+		return false, err
+	}
+
+	if applied == up {
+		tx.Rollback()
+		log.Printf("Skipped %s, which another migrate has already run", filepath.Base(file))
+		return false, nil
+	}
+
+	_, err = tx.Exec(string(contents))
+	if err != nil {
+		tx.Rollback()
+		return false, fmt.Errorf("%s: %s", filepath.Base(file), err)
+	}
+
+	if up {
+		_, err = tx.Exec(fmt.Sprintf("INSERT INTO %s (version, name) VALUES ($1, $2)", m.table), mg.Version, mg.Name)
+	} else {
+		_, err = tx.Exec(fmt.Sprintf("DELETE FROM %s WHERE version = $1", m.table), mg.Version)
+	}
+	if err != nil {
+		tx.Rollback()
+		return false, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return false, err
+	}
+
+	if up {
+		log.Printf("Applied %s", filepath.Base(file))
+	} else {
+		log.Printf("Rolled back %s", filepath.Base(file))
+	}
+
+	return true, nil
+}
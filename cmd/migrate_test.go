@@ -0,0 +1,203 @@
+package cmd
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeMigrationFiles(t *testing.T, names ...string) string {
+	folder, err := ioutil.TempDir("", "migrations")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, n := range names {
+		err = ioutil.WriteFile(filepath.Join(folder, n), []byte("SELECT 1;"), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return folder
+}
+
+func TestLoadMigrations(t *testing.T) {
+	folder := writeMigrationFiles(t,
+		"010-later.up.sql",
+		"010-later.down.sql",
+		"001-base.sql",
+		"002-add-users.up.sql",
+		"002-add-users.down.sql",
+		"README.md",
+	)
+	defer os.RemoveAll(folder)
+
+	migrations, err := loadMigrations(folder)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []struct {
+		Version int64
+		Name    string
+		HasDown bool
+	}{
+		{Version: 1, Name: "base", HasDown: false},
+		{Version: 2, Name: "add-users", HasDown: true},
+		{Version: 10, Name: "later", HasDown: true},
+	}
+
+	if len(migrations) != len(expected) {
+		t.Fatalf("Expected %d migrations, but had %d", len(expected), len(migrations))
+	}
+
+	for i, e := range expected {
+		m := migrations[i]
+		if m.Version != e.Version || m.Name != e.Name {
+			t.Errorf("Expected migration %03d-%s, but had %03d-%s", e.Version, e.Name, m.Version, m.Name)
+		}
+
+		if (len(m.Down) > 0) != e.HasDown {
+			t.Errorf("Expected HasDown=%t for migration %03d, but had down file '%s'", e.HasDown, m.Version, m.Down)
+		}
+	}
+}
+
+var invalidMigrationCases = []struct {
+	Name  string
+	Files []string
+}{
+	{
+		Name:  "conflictingNames",
+		Files: []string{"001-base.up.sql", "001-other.up.sql"},
+	},
+	{
+		Name:  "downWithoutUp",
+		Files: []string{"001-base.down.sql"},
+	},
+	{
+		Name:  "legacyAndPaired",
+		Files: []string{"001-base.sql", "001-base.up.sql"},
+	},
+}
+
+func TestLoadMigrationsInvalid(t *testing.T) {
+	for _, c := range invalidMigrationCases {
+		folder := writeMigrationFiles(t, c.Files...)
+
+		_, err := loadMigrations(folder)
+		if err == nil {
+			t.Errorf("%s: expected an error, but had none", c.Name)
+		}
+
+		os.RemoveAll(folder)
+	}
+}
+
+func TestOpenMigratorMySQL(t *testing.T) {
+	config := defaultConfig()
+	config.Generate.Database = databaseMySQL
+
+	_, err := openMigrator("", "root@tcp(localhost:3306)/app", config)
+	if err == nil || !strings.Contains(err.Error(), "only supports postgres") {
+		t.Errorf("Expected migrate to reject mysql, but had %v", err)
+	}
+}
+
+func TestWriteStatus(t *testing.T) {
+	at := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	migrations := []migration{{Version: 1, Name: "init"}, {Version: 2, Name: "todo"}}
+	applied := map[int64]time.Time{1: at, 9: at, 4: at, 12: at, 7: at}
+
+	var out bytes.Buffer
+	err := writeStatus(&out, migrations, applied)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var versions []string
+	for _, line := range strings.Split(strings.TrimSpace(out.String()), "\n")[1:] {
+		versions = append(versions, strings.Fields(line)[0])
+	}
+
+	// Missing files are listed last, in order, so the output is the same each
+	// time:
+	expected := "001,002,004,007,009,012"
+	if strings.Join(versions, ",") != expected {
+		t.Errorf("Expected versions %s, but had:\n%s", expected, out.String())
+	}
+
+	if !strings.Contains(out.String(), "pending") {
+		t.Errorf("Expected version 2 to be pending, but had:\n%s", out.String())
+	}
+}
+
+func TestCreateMigration(t *testing.T) {
+	folder := writeMigrationFiles(t, "001-base.up.sql", "001-base.down.sql")
+	defer os.RemoveAll(folder)
+
+	err := createMigration(folder, "Add Invoices")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, n := range []string{"002-add-invoices.up.sql", "002-add-invoices.down.sql"} {
+		if _, err := os.Stat(filepath.Join(folder, n)); err != nil {
+			t.Errorf("Expected %s to be created: %s", n, err)
+		}
+	}
+}
+
+func TestCreateModelMigrations(t *testing.T) {
+	folder := writeMigrationFiles(t, "001-base.up.sql", "001-base.down.sql")
+	defer os.RemoveAll(folder)
+
+	config := defaultConfig()
+	config.Generate.SchemaName = "estack"
+	config.Generate.Postgres = []PostgresGenerate{{ModelName: "TodoList", PK: "TodoListID", Audit: true}, {ModelName: "Tag", Search: []string{"name", "notes"}}}
+	config.Generate.Resolvers = []ResolverGenerate{{SingularModelName: "TodoList", Query: true, Subscribe: true}}
+
+	err := loadTemplates(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Running a second time must not add more migrations
+	for i := 0; i < 2; i++ {
+		err = createModelMigrations(folder, config)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	migrations, err := loadMigrations(folder)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(migrations) != 4 || migrations[1].Name != "todo-list-history" || migrations[2].Name != "tag-search" || migrations[3].Name != "todo-list-notify" {
+		t.Fatalf("Expected only 002-todo-list-history, 003-tag-search and 004-todo-list-notify to be added, but had %+v", migrations)
+	}
+
+	expected := map[string]string{
+		"002-todo-list-history.up.sql": "CREATE TABLE estack.todo_list_history",
+		"003-tag-search.up.sql":        "to_tsvector('english', coalesce(name::text, '') || ' ' || coalesce(notes::text, ''))",
+		"004-todo-list-notify.up.sql":  "NEW.todo_list_id::text",
+	}
+
+	for name, want := range expected {
+		up, err := ioutil.ReadFile(filepath.Join(folder, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !strings.Contains(string(up), want) {
+			t.Errorf("Expected %s to contain '%s', but had:\n%s", name, want, up)
+		}
+	}
+}
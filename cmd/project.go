@@ -0,0 +1,185 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"io/fs"
+	"io/ioutil"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+)
+
+// projectTemplate A starting point for new projects, made up of layers of
+// files under static/projects.  Files in later layers replace those with the
+// same name in earlier ones
+type projectTemplate struct {
+	Layers []string
+	Auth   bool // Includes user sessions, and the login and logout routes
+	OPA    bool // Loads an Open Policy Agent bundle, with a sample policy
+}
+
+// projectTemplates Templates built into estack, selected with init --template
+var projectTemplates = map[string]projectTemplate{
+	"minimal": {Layers: []string{"minimal"}},
+	"auth":    {Layers: []string{"minimal", "auth"}, Auth: true},
+	"full":    {Layers: []string{"minimal", "auth", "full"}, Auth: true, OPA: true},
+}
+
+// projectData Values available to files ending in .gotmpl when creating a
+// project
+type projectData struct {
+	PackageName string
+	Template    string
+	Database    string
+	Auth        bool
+	OPA         bool
+}
+
+// projectFile A file to create in a new project, and the layer it comes from
+type projectFile struct {
+	Layer fs.FS
+	Path  string
+}
+
+// loadProject Returns the files for the named project template, keyed by the
+// name of the file to create.  name is either a built-in template or a local
+// folder, which is layered over the minimal template so that it only needs to
+// contain the files it changes.  For databases other than postgres, the
+// layer named after the database replaces the postgres specific files
+func loadProject(name string, database string) (map[string]projectFile, projectTemplate, error) {
+	p, builtin := projectTemplates[name]
+	if !builtin {
+		p = projectTemplates["minimal"]
+	}
+
+	layerNames := p.Layers
+	switch database {
+	case databasePostgres:
+	case databaseMySQL:
+		if p.Auth {
+			return nil, p, fmt.Errorf("The %s template only supports %s.  Use the minimal template with %s", name, databasePostgres, database)
+		}
+		layerNames = append(append([]string{}, p.Layers...), database)
+	default:
+		return nil, p, fmt.Errorf("Unsupported database '%s'.  Use %s or %s", database, databasePostgres, databaseMySQL)
+	}
+
+	var layers []fs.FS
+	for _, l := range layerNames {
+		sub, err := fs.Sub(staticFiles, path.Join("static/projects", l))
+		if err != nil {
+			return nil, p, err
+		}
+		layers = append(layers, sub)
+	}
+
+	if !builtin {
+		info, err := os.Stat(name)
+		if err != nil || !info.IsDir() {
+			return nil, p, fmt.Errorf("Unknown template '%s'.  Use one of %s, or the path to a folder", name, strings.Join(projectTemplateNames(), ", "))
+		}
+		layers = append(layers, os.DirFS(name))
+	}
+
+	files := make(map[string]projectFile)
+	for _, layer := range layers {
+		err := fs.WalkDir(layer, ".", func(p string, d fs.DirEntry, err error) error {
+			if err != nil || d.IsDir() {
+				return err
+			}
+
+			files[strings.TrimSuffix(p, ".gotmpl")] = projectFile{Layer: layer, Path: p}
+			return nil
+		})
+		if err != nil {
+			return nil, p, err
+		}
+	}
+
+	return files, p, nil
+}
+
+func projectTemplateNames() []string {
+	var names []string
+	for n := range projectTemplates {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// renderProjectFile Returns the contents of the file to create, executing
+// .gotmpl files with data and formatting Go files
+func renderProjectFile(name string, f projectFile, data projectData) ([]byte, error) {
+	src, err := fs.ReadFile(f.Layer, f.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	if !strings.HasSuffix(f.Path, ".gotmpl") {
+		return src, nil
+	}
+
+	t, err := template.New(f.Path).Funcs(templateFuncs).Parse(string(src))
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	err = t.Execute(&buf, data)
+	if err != nil {
+		return nil, err
+	}
+
+	if filepath.Ext(name) != ".go" {
+		return buf.Bytes(), nil
+	}
+
+	contents, err := formatGo(name, buf.Bytes())
+	if err != nil {
+		return nil, sourceErrors(name, f.Path, buf.Bytes(), err)
+	}
+
+	return contents, nil
+}
+
+// writeProject Creates the project's files, either the Go files or all
+// others.  Existing files are left untouched
+func writeProject(files map[string]projectFile, data projectData, goFiles bool) error {
+	var names []string
+	for n := range files {
+		if (filepath.Ext(n) == ".go") == goFiles {
+			names = append(names, n)
+		}
+	}
+	sort.Strings(names)
+
+	var errs generateErrors
+	for _, n := range names {
+		dest := filepath.FromSlash(n)
+		if _, err := os.Stat(dest); err == nil {
+			log.Printf("%s already exists, so leaving it as is", dest)
+			continue
+		}
+
+		contents, err := renderProjectFile(n, files[n], data)
+		if err != nil {
+			errs.add(err)
+			continue
+		}
+
+		err = os.MkdirAll(filepath.Dir(dest), 0755)
+		if err == nil {
+			err = ioutil.WriteFile(dest, contents, 0644)
+		}
+		errs.add(err)
+	}
+
+	return errs.err()
+}
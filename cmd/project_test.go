@@ -0,0 +1,85 @@
+package cmd
+
+import (
+	"io/fs"
+	"strings"
+	"testing"
+)
+
+func TestProjectTemplatesRender(t *testing.T) {
+	for _, name := range projectTemplateNames() {
+		files, project, err := loadProject(name, databasePostgres)
+		if err != nil {
+			t.Errorf("%s: %s", name, err)
+			continue
+		}
+
+		data := projectData{PackageName: "github.com/example/todo", Template: name, Auth: project.Auth, OPA: project.OPA}
+		for n, f := range files {
+			if strings.HasSuffix(n, ".gotmpl") {
+				t.Errorf("%s: expected .gotmpl to be removed from %s", name, n)
+			}
+
+			_, err := renderProjectFile(n, f, data)
+			if err != nil {
+				t.Errorf("%s: %s", name, err)
+			}
+		}
+	}
+}
+
+func TestProjectLayers(t *testing.T) {
+	files, _, err := loadProject("auth", databasePostgres)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, n := range []string{"server.go", "gqlgen.yml", "auth.go"} {
+		if _, ok := files[n]; !ok {
+			t.Errorf("Expected auth template to include %s", n)
+		}
+	}
+
+	src, err := fs.ReadFile(files["migrations/001-base.up.sql"].Layer, files["migrations/001-base.up.sql"].Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(string(src), "session") {
+		t.Errorf("Expected the auth migration to replace the minimal one")
+	}
+
+	_, _, err = loadProject("no-such-template", databasePostgres)
+	if err == nil {
+		t.Errorf("Expected an error for an unknown template")
+	}
+}
+
+func TestProjectDatabase(t *testing.T) {
+	files, _, err := loadProject("minimal", databaseMySQL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data := projectData{PackageName: "github.com/example/todo", Template: "minimal", Database: databaseMySQL}
+	for n, f := range files {
+		_, err := renderProjectFile(n, f, data)
+		if err != nil {
+			t.Error(err)
+		}
+	}
+
+	src, err := fs.ReadFile(files["loader/init.go"].Layer, files["loader/init.go"].Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(string(src), "MySQLLoader") {
+		t.Errorf("Expected the mysql loader to replace the postgres one")
+	}
+
+	_, _, err = loadProject("auth", databaseMySQL)
+	if err == nil {
+		t.Errorf("Expected an error for a template that only supports postgres")
+	}
+}
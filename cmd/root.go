@@ -3,12 +3,9 @@ package cmd
 import (
 	"bufio"
 	"fmt"
-	"io/ioutil"
 	"log"
 	"os"
-	"path"
 	"regexp"
-	"runtime"
 	"text/template"
 
 	"github.com/urfave/cli"
@@ -22,8 +19,6 @@ var packageRx = regexp.MustCompile(`^module ([a-zA-Z0-9.\-\/]+)$`)
 
 // Execute Run estack
 func Execute() {
-	loadTemplates()
-
 	app := cli.NewApp()
 	app.Name = "estack"
 	app.Usage = genCmd.Usage
@@ -34,6 +29,9 @@ func Execute() {
 	app.Commands = []cli.Command{
 		genCmd,
 		initCmd,
+		migrateCmd,
+		scaffoldCmd,
+		watchCmd,
 	}
 
 	if err := app.Run(os.Args); err != nil {
@@ -43,26 +41,48 @@ func Execute() {
 
 }
 
-func loadTemplates() {
-	loaderTemplate = loadTemplateFromFile("loader/generated.gotmpl")
-	filterTemplate = loadTemplateFromFile("models/filter.gotmpl")
-	postgresTemplate = loadTemplateFromFile("loader/gen.gotmpl")
-	resolverTemplate = loadTemplateFromFile("resolvers/gen.gotmpl")
-}
-
-// loadTemplateFromFile Loads template from the package's local directory, under static folder
-func loadTemplateFromFile(input string) *template.Template {
-	_, filename, _, ok := runtime.Caller(0)
-	if !ok {
-		panic("No caller information")
+// loadTemplates Loads the templates used by generate, taking into account any
+// overrides configured for the project
+func loadTemplates(config Config) error {
+	setTemplateDir(config)
+
+	var err error
+	for _, t := range []struct {
+		Template **template.Template
+		Name     string
+	}{
+		{&loaderTemplate, "loader/generated.gotmpl"},
+		{&filterTemplate, "models/filter.gotmpl"},
+		{&postgresTemplate, "loader/gen.gotmpl"},
+		{&resolverTemplate, "resolvers/gen.gotmpl"},
+		{&authorisationTemplate, "authorisation/gen.gotmpl"},
+		{&authorisationSharedTemplate, "authorisation/generated.gotmpl"},
+		{&loaderLinkTemplate, "loader/links.gotmpl"},
+		{&resolverLinkTemplate, "resolvers/links.gotmpl"},
+		{&loaderInterfaceTemplate, "loader/interface.gotmpl"},
+		{&memoryLoaderTemplate, "loader/memory.gotmpl"},
+		{&historyMigrationTemplate, "migrations/history.gotmpl"},
+		{&notifyMigrationTemplate, "migrations/notify.gotmpl"},
+		{&searchMigrationTemplate, "migrations/search.gotmpl"},
+	} {
+		*t.Template, err = loadTemplateFromFile(config.flavourTemplate(t.Name))
+		if err != nil {
+			return err
+		}
 	}
 
-	source, err := ioutil.ReadFile(path.Dir(filename) + "/static/" + input)
+	return nil
+}
+
+// loadTemplateFromFile Loads the named template from the project's override
+// folder if present, otherwise from the static folder built into estack
+func loadTemplateFromFile(input string) (*template.Template, error) {
+	source, err := readTemplate(input)
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
 
-	return template.Must(template.New("").Funcs(templateFuncs).Parse(string(source)))
+	return template.New(input).Funcs(templateFuncs).Parse(string(source))
 }
 
 // loadPackageName Grabs the package/module name for this project from go.mod
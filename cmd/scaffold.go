@@ -0,0 +1,730 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/codemodus/kace"
+	"github.com/urfave/cli"
+	gcli "gnorm.org/gnorm/cli"
+	"gnorm.org/gnorm/environ"
+	yaml "gopkg.in/yaml.v2"
+)
+
+var scaffoldCmd = cli.Command{
+	Name:      "scaffold",
+	Usage:     "add config, gqlgen models and GraphQL types for the given database tables",
+	ArgsUsage: "<table> [table...]",
+	Flags: []cli.Flag{
+		cli.StringFlag{Name: "folder", Usage: "the project folder"},
+		cli.StringFlag{Name: "schema", Usage: "the database schema containing the tables.  Defaults to schemaName in config.yaml"},
+		cli.StringFlag{Name: "gqlgen", Usage: "the gqlgen config filename", Value: "gqlgen.yml"},
+		cli.StringFlag{Name: "graphql", Usage: "the GraphQL schema filename", Value: "schema.graphql"},
+	},
+	Action: func(ctx *cli.Context) {
+		if ctx.NArg() == 0 {
+			exit(fmt.Errorf("Provide the names of one or more tables to scaffold"))
+		}
+
+		if len(ctx.String("folder")) > 0 {
+			err := os.Chdir(ctx.String("folder"))
+			if err != nil {
+				exit(err)
+			}
+		}
+
+		config, err := readConfig("config.yaml")
+		if err != nil {
+			exit(err)
+		}
+
+		schemaName := ctx.String("schema")
+		if len(schemaName) == 0 {
+			schemaName = config.Generate.SchemaName
+		}
+		if len(schemaName) == 0 {
+			schemaName = "public"
+		}
+
+		schema, err := readGnormSchema(schemaName)
+		if err != nil {
+			exit(err)
+		}
+
+		var tables []scaffoldTable
+		for _, name := range ctx.Args() {
+			t, err := newScaffoldTable(config, schema, name)
+			if err != nil {
+				exit(err)
+			}
+			tables = append(tables, t)
+		}
+
+		err = scaffoldConfig("config.yaml", config, tables)
+		if err != nil {
+			exit(err)
+		}
+
+		err = scaffoldGQLGen(ctx.String("gqlgen"), tables)
+		if err != nil {
+			exit(err)
+		}
+
+		err = scaffoldGraphQL(ctx.String("graphql"), tables)
+		if err != nil {
+			exit(err)
+		}
+
+		log.Printf("Scaffolded %d table(s).  Provide hydrateModel, sort and editableUpdateFields functions for each, then run generate", len(tables))
+	},
+}
+
+// gnormSchema Schema metadata as reported by gnorm preview.  Only the values
+// needed for scaffolding are read
+type gnormSchema struct {
+	Name   string
+	DBName string
+	Tables []gnormTable
+}
+
+type gnormTable struct {
+	Name        string
+	DBName      string
+	Columns     []gnormColumn
+	PrimaryKeys []gnormColumn
+}
+
+type gnormColumn struct {
+	Name         string
+	DBName       string
+	Type         string
+	IsArray      bool
+	Nullable     bool
+	IsPrimaryKey bool
+}
+
+// readGnormSchema Reads metadata for the named schema from the database,
+// using the project's gnorm.toml so that names and types match those of the
+// generated code
+func readGnormSchema(schemaName string) (gnormSchema, error) {
+	var stdout, stderr bytes.Buffer
+	env := environ.Values{
+		Args:   []string{"preview", "--format", "json"},
+		Stderr: &stderr,
+		Stdout: &stdout,
+		Stdin:  os.Stdin,
+	}
+
+	if code := gcli.ParseAndRun(env); code != 0 {
+		return gnormSchema{}, fmt.Errorf("gnorm preview exited with code %d: %s", code, strings.TrimSpace(stderr.String()))
+	}
+
+	var data struct {
+		Schemas []gnormSchema
+	}
+	err := json.Unmarshal(stdout.Bytes(), &data)
+	if err != nil {
+		return gnormSchema{}, fmt.Errorf("Could not read gnorm schema metadata: %s", err)
+	}
+
+	for _, s := range data.Schemas {
+		if s.DBName == schemaName {
+			return s, nil
+		}
+	}
+
+	return gnormSchema{}, fmt.Errorf("Schema '%s' not found.  Check that it is listed in Schemas in gnorm.toml", schemaName)
+}
+
+// scaffoldTable Everything needed to scaffold a single table
+type scaffoldTable struct {
+	Postgres PostgresGenerate
+	Resolver ResolverGenerate
+	Model    string // Go type gqlgen should use for the model
+	Columns  []gnormColumn
+	Where    string // GraphQL schema file gnorm writes the table's Where input to
+}
+
+// newScaffoldTable Works out the config for the named table, inferring the
+// primary key and model struct from the table
+func newScaffoldTable(config Config, schema gnormSchema, name string) (scaffoldTable, error) {
+	var table *gnormTable
+	for i, t := range schema.Tables {
+		if t.DBName == name {
+			table = &schema.Tables[i]
+			break
+		}
+	}
+
+	if table == nil {
+		return scaffoldTable{}, fmt.Errorf("Table '%s' not found in schema '%s'", name, schema.DBName)
+	}
+
+	var pks []gnormColumn
+	for _, c := range table.Columns {
+		if c.IsPrimaryKey {
+			pks = append(pks, c)
+		}
+	}
+	if len(pks) == 0 {
+		pks = table.PrimaryKeys
+	}
+
+	if len(pks) != 1 {
+		return scaffoldTable{}, fmt.Errorf("Table '%s' must have a single column primary key, but has %d", name, len(pks))
+	}
+	pk := pks[0]
+
+	if !supportedPrimaryKeyTypes[pk.Type] {
+		return scaffoldTable{}, fmt.Errorf("Table '%s' has primary key of type %s, which is not supported", name, pk.Type)
+	}
+
+	pkg := strings.ToLower(table.Name)
+	modelPackage := fmt.Sprintf("%s/gnorm/%s/%s", config.PackageName, strings.ToLower(schema.Name), pkg)
+
+	st := scaffoldTable{
+		Postgres: PostgresGenerate{
+			ModelName:      table.Name,
+			ModelStruct:    pkg + ".Row",
+			ModelPackage:   modelPackage,
+			PmName:         table.Name,
+			PK:             pk.Name,
+			PrimaryKeyType: pk.Type,
+		},
+		Resolver: ResolverGenerate{
+			SingularModelName: table.Name,
+			PluralModelName:   plural(table.Name),
+			PrimaryKey:        pk.Name,
+			PrimaryKeyType:    pk.Type,
+			Query:             true,
+			OrderBy:           true,
+			Where:             true,
+		},
+		Model:   modelPackage + ".Row",
+		Columns: table.Columns,
+		Where:   fmt.Sprintf("gnorm/%s/where.graphql", strings.ToLower(schema.Name)),
+	}
+
+	// queryX takes the table's Sort and Order types, which have no values to
+	// define when no column can be sorted on.  Its query is then left for
+	// you to write:
+	if !hasDefinition(graphQLDefinitions(st), table.Name+"Order") {
+		st.Resolver.Query = false
+		st.Resolver.OrderBy = false
+	}
+
+	return st, nil
+}
+
+// supportedPrimaryKeyTypes Primary key types the loader templates can batch
+// requests for
+var supportedPrimaryKeyTypes = map[string]bool{
+	"int":       true,
+	"int64":     true,
+	"string":    true,
+	"uuid.UUID": true,
+}
+
+// plural Returns a naive English plural of name
+func plural(name string) string {
+	lower := strings.ToLower(name)
+
+	switch {
+	case strings.HasSuffix(lower, "y") && len(lower) > 1 && !strings.ContainsAny(lower[len(lower)-2:len(lower)-1], "aeiou"):
+		return name[:len(name)-1] + "ies"
+	case strings.HasSuffix(lower, "s"), strings.HasSuffix(lower, "x"), strings.HasSuffix(lower, "z"), strings.HasSuffix(lower, "ch"), strings.HasSuffix(lower, "sh"):
+		return name + "es"
+	}
+
+	return name + "s"
+}
+
+// scaffoldConfig Adds postgres and resolver entries to config.yaml for any
+// table that doesn't already have them
+func scaffoldConfig(filename string, config Config, tables []scaffoldTable) error {
+	var postgres []PostgresGenerate
+	var resolvers []ResolverGenerate
+
+	for _, t := range tables {
+		found := false
+		for _, p := range config.Generate.Postgres {
+			found = found || p.ModelName == t.Postgres.ModelName
+		}
+		if !found {
+			postgres = append(postgres, t.Postgres)
+		}
+
+		found = false
+		for _, r := range config.Generate.Resolvers {
+			found = found || r.SingularModelName == t.Resolver.SingularModelName
+		}
+		if !found {
+			resolvers = append(resolvers, t.Resolver)
+		}
+	}
+
+	src, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+
+	if len(postgres) > 0 {
+		src, err = insertYAMLEntries(src, []string{"generate", "postgres"}, postgres)
+		if err != nil {
+			return fmt.Errorf("%s: %s", filename, err)
+		}
+	}
+
+	if len(resolvers) > 0 {
+		src, err = insertYAMLEntries(src, []string{"generate", "resolvers"}, resolvers)
+		if err != nil {
+			return fmt.Errorf("%s: %s", filename, err)
+		}
+	}
+
+	// Make sure the result still reads as a valid config before writing it:
+	var check Config
+	err = yaml.Unmarshal(src, &check)
+	if err != nil {
+		return fmt.Errorf("%s: could not merge entries: %s", filename, err)
+	}
+
+	if len(check.Generate.Postgres) != len(config.Generate.Postgres)+len(postgres) || len(check.Generate.Resolvers) != len(config.Generate.Resolvers)+len(resolvers) {
+		return fmt.Errorf("%s: could not merge entries.  Add them by hand", filename)
+	}
+
+	return writeIfChanged(filename, src)
+}
+
+// scaffoldGQLGen Adds models entries to gqlgen.yml for any table that doesn't
+// already have them, for the gnorm Row and for the Where input, which is
+// passed to ParseWhere as a map.  The schema files gnorm writes the Where
+// inputs to are added to the list of schema files
+func scaffoldGQLGen(filename string, tables []scaffoldTable) error {
+	src, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+
+	var existing struct {
+		Schema interface{}            `yaml:"schema"`
+		Models map[string]interface{} `yaml:"models"`
+	}
+	err = yaml.Unmarshal(src, &existing)
+	if err != nil {
+		return fmt.Errorf("%s: %s", filename, err)
+	}
+
+	listed := make(map[string]bool)
+	switch s := existing.Schema.(type) {
+	case string:
+		listed[s] = true
+	case []interface{}:
+		for _, f := range s {
+			listed[fmt.Sprint(f)] = true
+		}
+	}
+
+	count := len(existing.Models)
+	if existing.Models == nil {
+		existing.Models = make(map[string]interface{})
+	}
+
+	var models yaml.MapSlice
+	var schemas []string
+	add := func(name string, model string) {
+		if _, ok := existing.Models[name]; ok {
+			return
+		}
+		existing.Models[name] = model
+
+		models = append(models, yaml.MapItem{
+			Key:   name,
+			Value: yaml.MapSlice{{Key: "model", Value: model}},
+		})
+	}
+
+	for _, t := range tables {
+		add(t.Postgres.ModelName, t.Model)
+
+		if !t.Resolver.Where {
+			continue
+		}
+		add(t.Resolver.SingularModelName+"Where", "map[string]interface{}")
+
+		if !listed[t.Where] {
+			listed[t.Where] = true
+			schemas = append(schemas, t.Where)
+		}
+	}
+
+	if len(models) == 0 && len(schemas) == 0 {
+		return nil
+	}
+
+	if len(schemas) > 0 {
+		src, err = insertYAMLEntries(src, []string{"schema"}, schemas)
+		if err != nil {
+			return fmt.Errorf("%s: could not add %s to schema, so add it by hand: %s", filename, strings.Join(schemas, ", "), err)
+		}
+	}
+
+	if len(models) > 0 {
+		src, err = insertYAMLEntries(src, []string{"models"}, models)
+		if err != nil {
+			return fmt.Errorf("%s: %s", filename, err)
+		}
+	}
+
+	var check struct {
+		Models map[string]interface{} `yaml:"models"`
+	}
+	err = yaml.Unmarshal(src, &check)
+	if err != nil || len(check.Models) != count+len(models) {
+		return fmt.Errorf("%s: could not merge models.  Add them by hand", filename)
+	}
+
+	return writeIfChanged(filename, src)
+}
+
+// yamlKeyRx Matches a line containing only a key, e.g., '  postgres: # DB'
+var yamlKeyRx = regexp.MustCompile(`^( *)([A-Za-z0-9_]+):\s*(#.*)?$`)
+
+// insertYAMLEntries Appends entries to the list or map found at path, adding
+// any missing keys along the way.  Editing the text, rather than unmarshalling
+// and marshalling the whole file, keeps comments and formatting intact
+func insertYAMLEntries(src []byte, path []string, entries interface{}) ([]byte, error) {
+	out, err := yaml.Marshal(entries)
+	if err != nil {
+		return nil, err
+	}
+	entryLines := strings.Split(strings.TrimSuffix(string(out), "\n"), "\n")
+
+	lines := strings.Split(strings.TrimSuffix(string(src), "\n"), "\n")
+	if len(src) == 0 {
+		lines = nil
+	}
+
+	start, end, indent := 0, len(lines), -1
+	for depth, key := range path {
+		childIndent := blockIndent(lines[start:end], indent)
+
+		found := false
+		for i := start; i < end; i++ {
+			m := yamlKeyRx.FindStringSubmatch(lines[i])
+			if m == nil || len(m[1]) != childIndent || m[2] != key {
+				continue
+			}
+
+			start, end, indent = i+1, yamlBlockEnd(lines, i+1, end, len(m[1])), len(m[1])
+			found = true
+			break
+		}
+
+		if found {
+			continue
+		}
+
+		if hasYAMLKey(lines[start:end], childIndent, key) {
+			return nil, fmt.Errorf("%s has an inline value that can't be added to", strings.Join(path[:depth+1], "."))
+		}
+
+		// Add the missing keys to the end of the block:
+		var added []string
+		for i, k := range path[depth:] {
+			added = append(added, strings.Repeat(" ", childIndent+i*2)+k+":")
+		}
+		itemIndent := childIndent + (len(path)-depth-1)*2
+		if !strings.HasPrefix(entryLines[0], "-") {
+			itemIndent += 2
+		}
+		added = append(added, indentLines(entryLines, itemIndent)...)
+
+		return joinYAML(lines, lastContent(lines, start, end), added), nil
+	}
+
+	// Entries in the existing block may be indented any amount:
+	itemIndent := blockIndent(lines[start:end], indent)
+	if itemIndent == indent && !strings.HasPrefix(entryLines[0], "-") {
+		itemIndent = indent + 2
+	}
+
+	return joinYAML(lines, lastContent(lines, start, end), indentLines(entryLines, itemIndent)), nil
+}
+
+// blockIndent Returns the indentation used by the first line of content in
+// a block, or the parent's indent + 2 when it has none
+func blockIndent(lines []string, parent int) int {
+	for _, l := range lines {
+		if isYAMLContent(l) {
+			return len(l) - len(strings.TrimLeft(l, " "))
+		}
+	}
+
+	if parent < 0 {
+		return 0
+	}
+
+	return parent + 2
+}
+
+// yamlBlockEnd Returns the index of the first line after start that is no
+// longer part of the block belonging to a key at the given indent.  List items
+// may share the key's indent
+func yamlBlockEnd(lines []string, start int, end int, indent int) int {
+	for i := start; i < end; i++ {
+		if !isYAMLContent(lines[i]) {
+			continue
+		}
+
+		n := len(lines[i]) - len(strings.TrimLeft(lines[i], " "))
+		if n < indent || (n == indent && !strings.HasPrefix(strings.TrimSpace(lines[i]), "-")) {
+			return i
+		}
+	}
+
+	return end
+}
+
+// lastContent Returns the index after the last line of content in the block,
+// so that new entries are added before any trailing comments or blank lines
+func lastContent(lines []string, start int, end int) int {
+	for i := end - 1; i >= start; i-- {
+		if isYAMLContent(lines[i]) {
+			return i + 1
+		}
+	}
+
+	return start
+}
+
+func hasYAMLKey(lines []string, indent int, key string) bool {
+	prefix := strings.Repeat(" ", indent) + key + ":"
+	for _, l := range lines {
+		if strings.HasPrefix(l, prefix) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func isYAMLContent(line string) bool {
+	t := strings.TrimSpace(line)
+	return len(t) > 0 && !strings.HasPrefix(t, "#")
+}
+
+func indentLines(lines []string, indent int) []string {
+	out := make([]string, len(lines))
+	for i, l := range lines {
+		out[i] = strings.Repeat(" ", indent) + l
+	}
+
+	return out
+}
+
+func joinYAML(lines []string, at int, added []string) []byte {
+	var out []string
+	out = append(out, lines[:at]...)
+	out = append(out, added...)
+	out = append(out, lines[at:]...)
+
+	return []byte(strings.Join(out, "\n") + "\n")
+}
+
+// graphQLDefinitionRx Matches the names of types defined in a GraphQL schema
+var graphQLDefinitionRx = regexp.MustCompile(`(?m)^\s*(?:type|input|enum|scalar|interface|union)\s+([A-Za-z0-9_]+)`)
+
+// scaffoldGraphQL Appends the GraphQL types for each table to the schema,
+// skipping any type that is already defined
+func scaffoldGraphQL(filename string, tables []scaffoldTable) error {
+	src, err := ioutil.ReadFile(filename)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	defined := make(map[string]bool)
+	for _, m := range graphQLDefinitionRx.FindAllStringSubmatch(string(src), -1) {
+		defined[m[1]] = true
+	}
+
+	var out bytes.Buffer
+	add := func(name string, definition string) {
+		if defined[name] {
+			return
+		}
+		defined[name] = true
+		out.WriteString("\n" + definition)
+	}
+
+	for _, t := range tables {
+		for _, d := range graphQLDefinitions(t) {
+			add(d.Name, d.Definition)
+		}
+	}
+
+	add("PageInfo", "type PageInfo {\n\thasNextPage: Boolean!\n\thasPreviousPage: Boolean!\n}\n")
+	add("SortDirection", "enum SortDirection {\n\tASC\n\tDESC\n}\n")
+	add("SortNulls", "enum SortNulls {\n\tFIRST\n\tLAST\n}\n")
+
+	// The Where inputs gnorm generates use Time if any table in the schema has
+	// a time column, not only those scaffolded:
+	for _, t := range tables {
+		for _, c := range t.Columns {
+			if graphQLType(c.Type) == "Time" || t.Resolver.Where {
+				add("Time", "scalar Time\n")
+			}
+		}
+	}
+
+	// Queries are added with 'extend type Query' so that the existing Query
+	// type is left untouched:
+	var queries []string
+	for _, t := range tables {
+		field := kace.Camel(t.Resolver.PluralModelName) + "Connection"
+		if regexp.MustCompile(`(?m)^\s*` + field + `\s*[(:]`).Match(src) {
+			continue
+		}
+
+		// Arguments are only added for the types that were defined, since
+		// empty inputs and enums are left out:
+		name := t.Resolver.SingularModelName
+		defs := graphQLDefinitions(t)
+		args := []string{"first: Int", "after: ID", "last: Int", "before: ID"}
+		if t.Resolver.Where {
+			args = append(args, fmt.Sprintf("where: %sWhere", name))
+		} else if hasDefinition(defs, name+"Filter") {
+			args = append(args, fmt.Sprintf("filters: %sFilter", name))
+		}
+		if hasDefinition(defs, name+"Order") {
+			args = append(args, fmt.Sprintf("orderBy: [%sOrder!]", name))
+		}
+
+		queries = append(queries, fmt.Sprintf("\t%s(%s): %sConnection!", field, strings.Join(args, ", "), t.Resolver.PluralModelName))
+	}
+	if len(queries) > 0 {
+		out.WriteString("\nextend type Query {\n" + strings.Join(queries, "\n") + "\n}\n")
+	}
+
+	if out.Len() == 0 {
+		return nil
+	}
+
+	if len(src) > 0 && !bytes.HasSuffix(src, []byte("\n")) {
+		src = append(src, '\n')
+	}
+
+	return writeIfChanged(filename, append(src, out.Bytes()...))
+}
+
+// graphQLDefinition A named type in a GraphQL schema
+type graphQLDefinition struct {
+	Name       string
+	Definition string
+}
+
+// graphQLDefinitions Returns the model, connection, edge, filter, sort and
+// order types for a table.  The filter is left out for tables using where
+func graphQLDefinitions(t scaffoldTable) []graphQLDefinition {
+	name := t.Resolver.SingularModelName
+
+	var fields, filters, sorts []string
+	for _, c := range t.Columns {
+		if c.IsPrimaryKey || c.Name == t.Resolver.PrimaryKey {
+			fields = append([]string{"\tid: ID!"}, fields...)
+			continue
+		}
+
+		gt := graphQLType(c.Type)
+		comment := ""
+		if len(gt) == 0 {
+			gt = "String"
+			comment = fmt.Sprintf(" # No GraphQL type for %s", c.Type)
+		}
+
+		ft := gt
+		if c.IsArray {
+			ft = "[" + gt + "!]"
+		}
+		if !c.Nullable {
+			ft += "!"
+		}
+
+		field := kace.Camel(c.Name)
+		fields = append(fields, fmt.Sprintf("\t%s: %s%s", field, ft, comment))
+
+		if len(comment) > 0 || c.IsArray {
+			continue
+		}
+
+		filters = append(filters, fmt.Sprintf("\t%s: %s", field, gt))
+		sorts = append(sorts, "\t"+kace.SnakeUpper(c.Name))
+	}
+
+	defs := []graphQLDefinition{
+		{name, fmt.Sprintf("type %s {\n%s\n}\n", name, strings.Join(fields, "\n"))},
+		{t.Resolver.PluralModelName + "Connection", fmt.Sprintf("type %sConnection {\n\ttotalCount: Int!\n\tedges: [%sEdge!]\n\tpageInfo: PageInfo!\n}\n", t.Resolver.PluralModelName, name)},
+		{name + "Edge", fmt.Sprintf("type %sEdge {\n\tcursor: ID!\n\tnode: %s!\n}\n", name, name)},
+	}
+
+	// Empty inputs and enums aren't valid GraphQL.  With where, gnorm
+	// generates the Where input in place of the Filter:
+	if len(filters) > 0 && !t.Resolver.Where {
+		defs = append(defs, graphQLDefinition{name + "Filter", fmt.Sprintf("input %sFilter {\n%s\n}\n", name, strings.Join(filters, "\n"))})
+	}
+	if len(sorts) > 0 {
+		defs = append(defs, graphQLDefinition{name + "Sort", fmt.Sprintf("enum %sSort {\n%s\n}\n", name, strings.Join(sorts, "\n"))})
+		defs = append(defs, graphQLDefinition{name + "Order", fmt.Sprintf("input %sOrder {\n\tfield: %sSort!\n\tdirection: SortDirection\n\tnulls: SortNulls\n}\n", name, name)})
+	}
+
+	return defs
+}
+
+// hasDefinition Returns true if defs includes a type with the given name
+func hasDefinition(defs []graphQLDefinition, name string) bool {
+	for _, d := range defs {
+		if d.Name == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+// graphQLType Returns the GraphQL scalar for a Go type from gnorm.toml's type
+// maps, or an empty string if there isn't one
+func graphQLType(goType string) string {
+	switch strings.TrimPrefix(goType, "*") {
+	case "string", "sql.NullString":
+		return "String"
+	case "int", "int32", "int64", "sql.NullInt64":
+		return "Int"
+	case "float32", "float64", "sql.NullFloat64":
+		return "Float"
+	case "bool", "sql.NullBool":
+		return "Boolean"
+	case "uuid.UUID", "uuid.NullUUID":
+		return "ID"
+	case "time.Time", "pq.NullTime", "mysql.NullTime":
+		return "Time"
+	}
+
+	return ""
+}
+
+// writeIfChanged Writes the file, logging what was updated
+func writeIfChanged(filename string, contents []byte) error {
+	existing, err := ioutil.ReadFile(filename)
+	if err == nil && bytes.Equal(existing, contents) {
+		return nil
+	}
+
+	log.Printf("Updating %s", filename)
+	return ioutil.WriteFile(filename, contents, 0644)
+}
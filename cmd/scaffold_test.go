@@ -0,0 +1,280 @@
+package cmd
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+var pluralCases = []struct {
+	Singular string
+	Plural   string
+}{
+	{"Todo", "Todos"},
+	{"Category", "Categories"},
+	{"Day", "Days"},
+	{"Address", "Addresses"},
+	{"Box", "Boxes"},
+	{"Batch", "Batches"},
+}
+
+func TestPlural(t *testing.T) {
+	for _, c := range pluralCases {
+		if p := plural(c.Singular); p != c.Plural {
+			t.Errorf("Expected plural of %s to be %s, but had %s", c.Singular, c.Plural, p)
+		}
+	}
+}
+
+var insertYAMLCases = []struct {
+	Name     string
+	Input    string
+	Expected string
+}{
+	{
+		Name:  "missingKeys",
+		Input: "packageName: \"github.com/example/todo\"\n",
+		Expected: `packageName: "github.com/example/todo"
+generate:
+  postgres:
+  - modelName: Todo
+`,
+	},
+	{
+		Name: "existingList",
+		Input: `packageName: "github.com/example/todo"
+generate:
+  schemaName: "public" # Schema used by gnorm
+  postgres:
+  - modelName: "User"
+
+  # Resolvers are added by hand
+  resolvers: []
+`,
+		Expected: `packageName: "github.com/example/todo"
+generate:
+  schemaName: "public" # Schema used by gnorm
+  postgres:
+  - modelName: "User"
+  - modelName: Todo
+
+  # Resolvers are added by hand
+  resolvers: []
+`,
+	},
+	{
+		Name: "missingChild",
+		Input: `generate:
+    schemaName: "public"
+migrate:
+  folder: "migrations"
+`,
+		Expected: `generate:
+    schemaName: "public"
+    postgres:
+    - modelName: Todo
+migrate:
+  folder: "migrations"
+`,
+	},
+}
+
+func TestInsertYAMLEntries(t *testing.T) {
+	entries := []map[string]string{{"modelName": "Todo"}}
+
+	for _, c := range insertYAMLCases {
+		out, err := insertYAMLEntries([]byte(c.Input), []string{"generate", "postgres"}, entries)
+		if err != nil {
+			t.Errorf("%s: %s", c.Name, err)
+			continue
+		}
+
+		if string(out) != c.Expected {
+			t.Errorf("%s: expected:\n%s\nbut had:\n%s", c.Name, c.Expected, out)
+			continue
+		}
+
+		var config Config
+		err = yaml.Unmarshal(out, &config)
+		if err != nil {
+			t.Errorf("%s: result is not valid YAML: %s", c.Name, err)
+		}
+	}
+}
+
+func TestInsertYAMLEntriesInline(t *testing.T) {
+	_, err := insertYAMLEntries([]byte("models: {}\n"), []string{"models"}, yaml.MapSlice{{Key: "Todo", Value: "x"}})
+	if err == nil {
+		t.Error("Expected an error adding to an inline map, but had none")
+	}
+}
+
+func TestGraphQLDefinitions(t *testing.T) {
+	table := scaffoldTable{
+		Resolver: ResolverGenerate{
+			SingularModelName: "Todo",
+			PluralModelName:   "Todos",
+			PrimaryKey:        "TodoID",
+		},
+		Columns: []gnormColumn{
+			{Name: "TodoID", Type: "int", IsPrimaryKey: true},
+			{Name: "Content", Type: "string"},
+			{Name: "DueAt", Type: "pq.NullTime", Nullable: true},
+			{Name: "Attributes", Type: "gnorm.Jsonb"},
+		},
+	}
+
+	defs := make(map[string]string)
+	for _, d := range graphQLDefinitions(table) {
+		defs[d.Name] = d.Definition
+	}
+
+	expected := map[string][]string{
+		"Todo":            {"id: ID!", "content: String!", "dueAt: Time\n", "attributes: String! # No GraphQL type for gnorm.Jsonb"},
+		"TodosConnection": {"edges: [TodoEdge!]", "pageInfo: PageInfo!"},
+		"TodoEdge":        {"node: Todo!"},
+		"TodoFilter":      {"content: String", "dueAt: Time"},
+		"TodoSort":        {"CONTENT", "DUE_AT"},
+		"TodoOrder":       {"field: TodoSort!", "direction: SortDirection", "nulls: SortNulls"},
+	}
+
+	for name, parts := range expected {
+		d, ok := defs[name]
+		if !ok {
+			t.Errorf("Expected a definition for %s", name)
+			continue
+		}
+
+		for _, p := range parts {
+			if !strings.Contains(d, p) {
+				t.Errorf("Expected %s to contain '%s', but had:\n%s", name, p, d)
+			}
+		}
+	}
+
+	if strings.Contains(defs["TodoFilter"], "attributes") {
+		t.Errorf("Expected fields without a GraphQL type to be left out of the filter")
+	}
+}
+
+func TestGraphQLDefinitionsWhere(t *testing.T) {
+	table := scaffoldTable{
+		Resolver: ResolverGenerate{SingularModelName: "Todo", PluralModelName: "Todos", PrimaryKey: "TodoID", Where: true},
+		Columns:  []gnormColumn{{Name: "TodoID", Type: "int", IsPrimaryKey: true}, {Name: "Content", Type: "string"}},
+	}
+
+	for _, d := range graphQLDefinitions(table) {
+		if d.Name == "TodoFilter" {
+			t.Errorf("Expected no filter for a table using where, but had:\n%s", d.Definition)
+		}
+	}
+}
+
+func TestScaffoldGraphQLUnsortable(t *testing.T) {
+	folder, err := ioutil.TempDir("", "scaffold")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(folder)
+
+	// Only the primary key has a GraphQL type that can be filtered or sorted
+	// on, so no Filter, Sort or Order types are defined:
+	columns := []gnormColumn{
+		{Name: "DocumentID", Type: "int", IsPrimaryKey: true},
+		{Name: "Body", Type: "gnorm.Jsonb"},
+		{Name: "Tags", Type: "string", IsArray: true},
+	}
+
+	for _, where := range []bool{false, true} {
+		filename := filepath.Join(folder, fmt.Sprintf("schema-%t.graphql", where))
+		table := scaffoldTable{
+			Resolver: ResolverGenerate{SingularModelName: "Document", PluralModelName: "Documents", PrimaryKey: "DocumentID", Where: where},
+			Columns:  columns,
+		}
+
+		err = scaffoldGraphQL(filename, []scaffoldTable{table})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		out, err := ioutil.ReadFile(filename)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, missing := range []string{"DocumentFilter", "DocumentSort", "DocumentOrder", "orderBy"} {
+			if strings.Contains(string(out), missing) {
+				t.Errorf("where %t: expected no %s, but had:\n%s", where, missing, out)
+			}
+		}
+
+		query := "documentsConnection(first: Int, after: ID, last: Int, before: ID): DocumentsConnection!"
+		if where {
+			query = "documentsConnection(first: Int, after: ID, last: Int, before: ID, where: DocumentWhere): DocumentsConnection!"
+		}
+		if !strings.Contains(string(out), query) {
+			t.Errorf("where %t: expected query '%s', but had:\n%s", where, query, out)
+		}
+	}
+}
+
+func TestScaffoldGQLGen(t *testing.T) {
+	folder, err := ioutil.TempDir("", "scaffold")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(folder)
+
+	filename := filepath.Join(folder, "gqlgen.yml")
+	err = ioutil.WriteFile(filename, []byte("schema:\n- schema.graphql\nmodels:\n  User:\n    model: x/user.Row\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tables := []scaffoldTable{
+		{Postgres: PostgresGenerate{ModelName: "Todo"}, Resolver: ResolverGenerate{SingularModelName: "Todo", Where: true}, Model: "x/todo.Row", Where: "gnorm/public/where.graphql"},
+		{Postgres: PostgresGenerate{ModelName: "Tag"}, Resolver: ResolverGenerate{SingularModelName: "Tag", Where: true}, Model: "x/tag.Row", Where: "gnorm/public/where.graphql"},
+	}
+
+	// Running again should leave the file as it is:
+	for i := 0; i < 2; i++ {
+		err = scaffoldGQLGen(filename, tables)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	out, err := ioutil.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var config struct {
+		Schema []string
+		Models map[string]struct{ Model string }
+	}
+	err = yaml.Unmarshal(out, &config)
+	if err != nil {
+		t.Fatalf("Result is not valid YAML: %s\n%s", err, out)
+	}
+
+	if strings.Join(config.Schema, ",") != "schema.graphql,gnorm/public/where.graphql" {
+		t.Errorf("Expected the where schema to be listed once, but had %v", config.Schema)
+	}
+
+	expected := map[string]string{"User": "x/user.Row", "Todo": "x/todo.Row", "TodoWhere": "map[string]interface{}", "Tag": "x/tag.Row", "TagWhere": "map[string]interface{}"}
+	for name, model := range expected {
+		if config.Models[name].Model != model {
+			t.Errorf("Expected %s to use %s, but had '%s'", name, model, config.Models[name].Model)
+		}
+	}
+
+	if len(config.Models) != len(expected) {
+		t.Errorf("Expected %d models, but had %d:\n%s", len(expected), len(config.Models), out)
+	}
+}
@@ -0,0 +1,82 @@
+package cmd
+
+import (
+	"embed"
+	"io/fs"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+)
+
+// staticFiles Templates and starter files used to initialise and generate
+// projects, compiled into the binary so that estack works when installed
+//
+//go:embed static
+var staticFiles embed.FS
+
+// templateDir Project folder containing overrides for any of the files under
+// static.  Set from config.yaml when generating
+var templateDir string
+
+// setTemplateDir Configures the override folder from the config
+func setTemplateDir(config Config) {
+	templateDir = config.Generate.TemplateDir
+}
+
+// readTemplate Returns the named file, e.g., loader/gen.gotmpl.  The
+// project's override folder is checked first, falling back to the copy built
+// into estack
+func readTemplate(name string) ([]byte, error) {
+	if len(templateDir) > 0 {
+		input, err := ioutil.ReadFile(filepath.Join(templateDir, filepath.FromSlash(name)))
+		if err == nil {
+			return input, nil
+		}
+
+		if !os.IsNotExist(err) {
+			return nil, err
+		}
+	}
+
+	return staticFiles.ReadFile(path.Join("static", name))
+}
+
+// listTemplates Returns the names of the files in folder, from both the
+// override folder and the built-in files
+func listTemplates(folder string) ([]string, error) {
+	found := make(map[string]bool)
+
+	entries, err := fs.ReadDir(staticFiles, path.Join("static", folder))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	for _, e := range entries {
+		if !e.IsDir() {
+			found[e.Name()] = true
+		}
+	}
+
+	if len(templateDir) > 0 {
+		files, err := ioutil.ReadDir(filepath.Join(templateDir, filepath.FromSlash(folder)))
+		if err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+
+		for _, f := range files {
+			if !f.IsDir() {
+				found[f.Name()] = true
+			}
+		}
+	}
+
+	var names []string
+	for n := range found {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+
+	return names, nil
+}
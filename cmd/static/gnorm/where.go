@@ -29,6 +29,17 @@ func InInt(field string, values []int) In {
 	return In{Field: field, Values: newVals}
 }
 
+// InInt64 Returns an in clause for an array of 64 bit integers
+func InInt64(field string, values []int64) In {
+	newVals := make([]interface{}, len(values))
+
+	for i, v := range values {
+		newVals[i] = v
+	}
+
+	return In{Field: field, Values: newVals}
+}
+
 // In Return a clause for values in an array
 type In struct {
 	Field  string
@@ -0,0 +1,3 @@
+DROP TABLE todo;
+DROP TABLE "session";
+DROP TABLE "user";
@@ -0,0 +1,17 @@
+package api.query.todo
+
+# Checked by the generated authorisation.Todo and authorisation.TodoFetch
+# functions.  input.user is the logged in user, and input.todo the todo being
+# requested
+
+default allow = false
+
+# Admins can see every todo
+allow {
+	input.user.Admin
+}
+
+# Everyone else can only see their own
+allow {
+	input.todo.UserID == input.user.UserID
+}
@@ -0,0 +1,2 @@
+DROP TABLE todo;
+DROP TABLE "user";
@@ -0,0 +1,72 @@
+package cmd
+
+import (
+	"go/build"
+	"go/parser"
+	"go/token"
+	"io/fs"
+	"io/ioutil"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+var requireRx = regexp.MustCompile(`(?m)^\s*(?:require\s+)?([a-z0-9.\-]+\.[a-z]+/[^\s]+)\s+v[^\s]+`)
+
+// TestStaticGoFiles Checks that the Go files under static, which are copied
+// into projects as they are, are built by go vet ./... and only import
+// packages estack's own go.mod provides.  Anything else belongs in a template
+func TestStaticGoFiles(t *testing.T) {
+	mod, err := ioutil.ReadFile("../go.mod")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	modules := []string{"github.com/episub/estack"}
+	for _, m := range requireRx.FindAllStringSubmatch(string(mod), -1) {
+		modules = append(modules, m[1])
+	}
+
+	err = fs.WalkDir(staticFiles, "static", func(name string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || path.Ext(name) != ".go" {
+			return err
+		}
+
+		ok, err := build.Default.MatchFile(path.Dir(name), path.Base(name))
+		if err != nil {
+			return err
+		}
+		if !ok {
+			t.Errorf("%s is excluded from the build, so go vet doesn't check it", name)
+		}
+
+		f, err := parser.ParseFile(token.NewFileSet(), name, nil, parser.ImportsOnly)
+		if err != nil {
+			return err
+		}
+
+		for _, i := range f.Imports {
+			p, _ := strconv.Unquote(i.Path.Value)
+			if !strings.Contains(strings.Split(p, "/")[0], ".") {
+				continue
+			}
+
+			provided := false
+			for _, m := range modules {
+				if p == m || strings.HasPrefix(p, m+"/") {
+					provided = true
+				}
+			}
+			if !provided {
+				t.Errorf("%s imports %s, which isn't in go.mod", name, p)
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
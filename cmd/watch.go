@@ -0,0 +1,333 @@
+package cmd
+
+import (
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/radovskyb/watcher"
+	"github.com/urfave/cli"
+)
+
+// stage A step of code generation that can be rerun on its own
+type stage int
+
+const (
+	stageMigrate stage = 1 << iota // Apply pending migrations
+	stageGnorm                     // Regenerate database code with gnorm
+	stageTasks                     // Render estack's loader, models and resolver templates
+	stageGQL                       // Regenerate GraphQL code with gqlgen
+
+	stageAll = stageGnorm | stageTasks | stageGQL
+)
+
+// String Lists the stages that are set
+func (s stage) String() string {
+	var names []string
+	for _, n := range []struct {
+		Stage stage
+		Name  string
+	}{
+		{stageMigrate, "migrate"},
+		{stageGnorm, "gnorm"},
+		{stageTasks, "templates"},
+		{stageGQL, "gqlgen"},
+	} {
+		if s&n.Stage != 0 {
+			names = append(names, n.Name)
+		}
+	}
+
+	return strings.Join(names, ", ")
+}
+
+// watchedPaths Files and folders that trigger regeneration when changed
+var watchedPaths = []string{
+	"schema.graphql",
+	"gqlgen.yml",
+	"config.yaml",
+	"gnorm.toml",
+	"templates",
+	"migrations",
+}
+
+var watchCmd = cli.Command{
+	Name:  "watch",
+	Usage: "regenerate code whenever the schema, config, templates or migrations change",
+	Flags: []cli.Flag{
+		cli.StringFlag{Name: "config, c", Usage: "the config filename"},
+		cli.StringFlag{Name: "folder", Usage: "the project folder"},
+		cli.DurationFlag{Name: "debounce", Value: 500 * time.Millisecond, Usage: "how long to wait for changes to settle before regenerating"},
+		cli.DurationFlag{Name: "interval", Value: 250 * time.Millisecond, Usage: "how often to poll for changes"},
+	},
+	Action: func(ctx *cli.Context) {
+		if len(ctx.String("folder")) > 0 {
+			err := os.Chdir(ctx.String("folder"))
+			if err != nil {
+				exit(err)
+			}
+		}
+
+		err := loadPackageName()
+		if err != nil {
+			exit(err)
+		}
+
+		root, err := os.Getwd()
+		if err != nil {
+			exit(err)
+		}
+
+		// Start from a migrated and fully generated project, so that later runs
+		// only need to redo the affected stages
+		runStages(ctx, stageMigrate|stageAll)
+
+		w := watcher.New()
+		w.FilterOps(watcher.Rename, watcher.Move, watcher.Write, watcher.Create, watcher.Remove)
+
+		// Project template overrides are watched too, though changing the
+		// folder itself requires restarting watch
+		var overrides string
+		paths := watchedPaths
+		if config, err := readConfig("config.yaml"); err == nil && len(config.Generate.TemplateDir) > 0 {
+			overrides = config.Generate.TemplateDir
+			paths = append(paths, overrides)
+		}
+
+		watching := make(map[string]bool)
+		err = watchPaths(w, paths, watching)
+		if err != nil {
+			exit(err)
+		}
+
+		go func() {
+			err := w.Start(ctx.Duration("interval"))
+			if err != nil {
+				exit(err)
+			}
+		}()
+
+		log.Printf("Watching %s for changes", strings.Join(paths, ", "))
+
+		var pending stage
+		timer := time.NewTimer(ctx.Duration("debounce"))
+		timer.Stop()
+
+		for {
+			select {
+			case event := <-w.Event:
+				// Paths created or removed since startup need their watches
+				// updated before they are picked up
+				if event.Op != watcher.Write {
+					err := watchPaths(w, paths, watching)
+					if err != nil {
+						log.Printf("Watch error: %s", err)
+					}
+				}
+
+				s := stagesFor(root, overrides, event.Path)
+				if event.Op == watcher.Rename || event.Op == watcher.Move {
+					s |= stagesFor(root, overrides, event.OldPath)
+				}
+
+				if s == 0 {
+					continue
+				}
+
+				log.Printf("%s", event)
+				pending |= s
+
+				// Wait for the burst of changes to settle before regenerating.
+				// The timer must be stopped and drained before it is reset:
+				if !timer.Stop() {
+					select {
+					case <-timer.C:
+					default:
+					}
+				}
+				timer.Reset(ctx.Duration("debounce"))
+			case <-timer.C:
+				runStages(ctx, pending)
+				pending = 0
+			case err := <-w.Error:
+				log.Printf("Watch error: %s", err)
+			case <-w.Closed:
+				return
+			}
+		}
+	},
+}
+
+// stagesFor Returns the stages that need to run when the file at path
+// changes.  overrides is the project's template override folder, if any
+func stagesFor(root string, overrides string, path string) stage {
+	rel, err := filepath.Rel(root, path)
+	if err != nil {
+		return 0
+	}
+	rel = filepath.ToSlash(rel)
+
+	if len(overrides) > 0 {
+		if filepath.IsAbs(overrides) {
+			overrides, _ = filepath.Rel(root, overrides)
+		}
+
+		dir := filepath.ToSlash(filepath.Clean(overrides))
+		if strings.HasPrefix(rel, dir+"/") {
+			rel = strings.TrimPrefix(rel, dir+"/")
+
+			// Overridden gnorm templates, including those for a specific
+			// database, are copied into place by the gnorm stage, and all
+			// others are rendered by estack
+			if strings.HasPrefix(rel, "templates/") || strings.HasPrefix(rel, databaseMySQL+"/templates/") {
+				return stageGnorm | stageGQL
+			}
+			return stageTasks | stageGQL
+		}
+	}
+
+	switch {
+	case rel == "schema.graphql" || rel == "gqlgen.yml":
+		return stageGQL
+	case rel == "gnorm.toml":
+		// Holds the type maps and params the gnorm templates read
+		return stageGnorm | stageGQL
+	case rel == "config.yaml":
+		// The config also decides which resolvers and models gqlgen binds
+		return stageTasks | stageGQL
+	case rel == "templates" || strings.HasPrefix(rel, "templates/"):
+		// Database code changes can affect how gqlgen binds models
+		return stageGnorm | stageGQL
+	case rel == "migrations" || strings.HasPrefix(rel, "migrations/"):
+		return stageMigrate | stageGnorm | stageGQL
+	}
+
+	return 0
+}
+
+// watchPaths Watches each of paths that exists and is not yet watched,
+// recording them in watching.  A path that does not exist yet has its nearest
+// existing parent folder watched instead, so that it is picked up once it is
+// created
+func watchPaths(w *watcher.Watcher, paths []string, watching map[string]bool) error {
+	for _, p := range paths {
+		if _, err := os.Stat(p); err != nil {
+			// Removed paths are watched again if they are recreated
+			delete(watching, p)
+
+			parent := filepath.Dir(filepath.Clean(p))
+			for parent != "." && parent != string(filepath.Separator) {
+				if _, err := os.Stat(parent); err == nil {
+					break
+				}
+				parent = filepath.Dir(parent)
+			}
+
+			if watching[parent] {
+				continue
+			}
+
+			err = w.Add(parent)
+			if err != nil {
+				return err
+			}
+			watching[parent] = true
+			continue
+		}
+
+		if watching[p] {
+			continue
+		}
+
+		err := w.AddRecursive(p)
+		if err != nil {
+			return err
+		}
+		watching[p] = true
+	}
+
+	return nil
+}
+
+// runStages Runs the given stages in order, reporting any errors without
+// stopping.  Later stages still run if an earlier one fails, since they may
+// not depend on it
+func runStages(ctx *cli.Context, stages stage) {
+	log.Printf("Regenerating: %s", stages)
+	start := time.Now()
+
+	var failed []string
+	fail := func(name string, err error) {
+		log.Printf("%s failed: %s", name, err)
+		failed = append(failed, name)
+	}
+
+	// watch has already moved into the project folder, so paths are relative
+	// to it
+	config, err := readConfig("config.yaml")
+	if err != nil {
+		fail("config", err)
+		return
+	}
+
+	// Migrations are only applied for postgres, as with estack migrate
+	if config.Generate.Database != databasePostgres {
+		stages &^= stageMigrate
+	}
+
+	if stages&stageMigrate != 0 {
+		m, err := openMigrator("", "", config)
+		if err == nil {
+			err = m.up(0)
+			m.db.Close()
+		}
+		if err != nil {
+			fail("migrate", err)
+		}
+	}
+
+	err = loadTemplates(config)
+	if err != nil {
+		fail("templates", err)
+		stages &^= stageTasks
+	}
+
+	if stages&stageGnorm != 0 {
+		// Templates are not copied over, since they are likely the very files
+		// being edited, unless the project keeps its overrides elsewhere
+		err = generateGnorm(config, len(config.Generate.TemplateDir) > 0)
+		if err != nil {
+			fail("gnorm", err)
+		}
+	}
+
+	if stages&stageTasks != 0 {
+		// New migrations are picked up by the watcher, and applied on the
+		// next run
+		err = createModelMigrations(config.Migrate.Folder, config)
+		if err != nil {
+			fail("migrations", err)
+		}
+
+		err = generateFiles(".", config, generateTasks(config))
+		if err != nil {
+			fail("templates", err)
+		}
+	}
+
+	if stages&stageGQL != 0 {
+		_, err = runGQLGen(ctx.String("config"))
+		if err != nil {
+			fail("gqlgen", err)
+		}
+	}
+
+	if len(failed) > 0 {
+		log.Printf("Regeneration finished with errors in: %s", strings.Join(failed, ", "))
+		return
+	}
+
+	log.Printf("Regenerated in %s", time.Since(start).Round(time.Millisecond))
+}
@@ -0,0 +1,38 @@
+package cmd
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+var stagesForCases = []struct {
+	Overrides string
+	Path      string
+	Expected  stage
+}{
+	{"", "schema.graphql", stageGQL},
+	{"", "gqlgen.yml", stageGQL},
+	{"", "config.yaml", stageTasks | stageGQL},
+	{"", "gnorm.toml", stageGnorm | stageGQL},
+	{"", "templates", stageGnorm | stageGQL},
+	{"", "templates/table.gotmpl", stageGnorm | stageGQL},
+	{"", "migrations/001-init.up.sql", stageMigrate | stageGnorm | stageGQL},
+	{"", "loader/gen_todo.go", 0},
+	{"", "templatesx/table.gotmpl", 0},
+	{"estack", "estack/loader/gen.gotmpl", stageTasks | stageGQL},
+	{"estack", "estack/templates/table.gotmpl", stageGnorm | stageGQL},
+	{"estack", "estack/mysql/templates/db.gotmpl", stageGnorm | stageGQL},
+	{"estack", "estack/resolvers/gen.gotmpl", stageTasks | stageGQL},
+	{"/project/estack", "estack/loader/gen.gotmpl", stageTasks | stageGQL},
+}
+
+func TestStagesFor(t *testing.T) {
+	root := filepath.FromSlash("/project")
+
+	for _, c := range stagesForCases {
+		s := stagesFor(root, filepath.FromSlash(c.Overrides), filepath.Join(root, filepath.FromSlash(c.Path)))
+		if s != c.Expected {
+			t.Errorf("Expected %s with overrides '%s' to run '%s', but had '%s'", c.Path, c.Overrides, c.Expected, s)
+		}
+	}
+}
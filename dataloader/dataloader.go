@@ -0,0 +1,243 @@
+package dataloader
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	opentracing "github.com/opentracing/opentracing-go"
+)
+
+// ErrNotFound Returned by Load when fetch returns no value for the key
+var ErrNotFound = fmt.Errorf("No value found for key")
+
+// contextKey Key the request's loaders are stored under in its context.  An
+// unexported type can't collide with keys set by other packages
+type contextKey struct{}
+
+// Config Settings for the loaders created for each request
+type Config struct {
+	// Wait How long to collect keys for before fetching them together
+	Wait time.Duration
+	// MaxBatch Most keys fetched together.  A full batch is fetched straight
+	// away, without waiting.  0 for no limit
+	MaxBatch int
+}
+
+var config = Config{Wait: time.Millisecond, MaxBatch: 100}
+
+// SetConfig Changes the settings used by loaders created afterwards.  Call it
+// before starting the server
+func SetConfig(c Config) {
+	config = c
+}
+
+// FetchFunc Fetches the values for keys, returning them by key.  Keys missing
+// from the result weren't found
+type FetchFunc func(ctx context.Context, keys []interface{}) (map[interface{}]interface{}, error)
+
+// loaders The loaders for one request, by name
+type loaders struct {
+	ctx    context.Context
+	config Config
+	mx     sync.Mutex
+	byName map[string]*loader
+}
+
+// loader Batches and remembers the keys loaded under one name
+type loader struct {
+	fetch FetchFunc
+	cache map[interface{}]*result
+	batch *batch // Keys waiting to be fetched, if any
+}
+
+// batch Keys collected to be fetched together
+type batch struct {
+	keys    []interface{}
+	results []*result
+	fetched bool
+}
+
+// result The value for a key, available once done is closed
+type result struct {
+	done    chan struct{}
+	value   interface{}
+	err     error
+	waiting int // Loads still waiting, so keys no longer wanted aren't fetched
+}
+
+// NewContext Returns a copy of ctx holding a new set of loaders.  Values
+// loaded with it are batched and remembered for as long as it is used, so
+// create one per request, as middleware.DefaultMW does.  Batches are fetched
+// using ctx, so that they are traced and cancelled along with the request
+func NewContext(ctx context.Context) context.Context {
+	return context.WithValue(ctx, contextKey{}, &loaders{
+		ctx:    ctx,
+		config: config,
+		byName: make(map[string]*loader),
+	})
+}
+
+// Load Returns the value for key, fetched by fetch along with the other keys
+// loaded under name, and remembered for the rest of the request.  Without
+// loaders in ctx, such as outside of a request, key is fetched on its own
+func Load(ctx context.Context, name string, key interface{}, fetch FetchFunc) (interface{}, error) {
+	ls, ok := ctx.Value(contextKey{}).(*loaders)
+	if !ok {
+		return fetchOne(ctx, key, fetch)
+	}
+
+	return ls.load(ctx, name, key, fetch)
+}
+
+// Clear Forgets the value remembered for key, so that the next Load fetches
+// it again.  Call it after changing the value
+func Clear(ctx context.Context, name string, key interface{}) {
+	ls, ok := ctx.Value(contextKey{}).(*loaders)
+	if !ok {
+		return
+	}
+
+	ls.mx.Lock()
+	defer ls.mx.Unlock()
+
+	if l, ok := ls.byName[name]; ok {
+		delete(l.cache, key)
+	}
+}
+
+// fetchOne Fetches the value for key on its own
+func fetchOne(ctx context.Context, key interface{}, fetch FetchFunc) (interface{}, error) {
+	values, err := fetch(ctx, []interface{}{key})
+	if err != nil {
+		return nil, err
+	}
+
+	v, ok := values[key]
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	return v, nil
+}
+
+// load Returns the remembered value for key, or adds key to the next batch
+// and waits for it.  Returns early if ctx is done
+func (ls *loaders) load(ctx context.Context, name string, key interface{}, fetch FetchFunc) (interface{}, error) {
+	ls.mx.Lock()
+	l, ok := ls.byName[name]
+	if !ok {
+		l = &loader{fetch: fetch, cache: make(map[interface{}]*result)}
+		ls.byName[name] = l
+	}
+
+	r, ok := l.cache[key]
+	if !ok {
+		r = &result{done: make(chan struct{})}
+		l.cache[key] = r
+		ls.add(l, name, key, r)
+	}
+	r.waiting++
+	ls.mx.Unlock()
+
+	select {
+	case <-r.done:
+		return r.value, r.err
+	case <-ctx.Done():
+		ls.mx.Lock()
+		r.waiting--
+		ls.mx.Unlock()
+
+		return nil, ctx.Err()
+	}
+}
+
+// add Adds key to the batch being collected by l, starting one if there is
+// none, and fetches the batch straight away once it is full.  Called with
+// ls.mx held
+func (ls *loaders) add(l *loader, name string, key interface{}, r *result) {
+	if l.batch == nil {
+		b := &batch{}
+		l.batch = b
+		time.AfterFunc(ls.config.Wait, func() { ls.fetch(l, name, b) })
+	}
+
+	b := l.batch
+	b.keys = append(b.keys, key)
+	b.results = append(b.results, r)
+
+	if ls.config.MaxBatch > 0 && len(b.keys) >= ls.config.MaxBatch {
+		l.batch = nil
+		go ls.fetch(l, name, b)
+	}
+}
+
+// fetch Fetches the keys in b that are still wanted, unless b has already
+// been fetched.  Errors aren't remembered, so that the key is fetched again
+// by the next Load
+func (ls *loaders) fetch(l *loader, name string, b *batch) {
+	var keys []interface{}
+	var results []*result
+
+	ls.mx.Lock()
+	if b.fetched {
+		ls.mx.Unlock()
+		return
+	}
+	b.fetched = true
+
+	if l.batch == b {
+		l.batch = nil
+	}
+
+	for i, r := range b.results {
+		if r.waiting > 0 {
+			keys = append(keys, b.keys[i])
+			results = append(results, r)
+			continue
+		}
+
+		// Every Load of the key gave up waiting
+		r.err = context.Canceled
+		ls.forget(l, b.keys[i], r)
+		close(r.done)
+	}
+	ls.mx.Unlock()
+
+	if len(keys) == 0 {
+		return
+	}
+
+	span, ctx := opentracing.StartSpanFromContext(ls.ctx, "Load "+name)
+	span.SetTag("keys", len(keys))
+	values, err := l.fetch(ctx, keys)
+	span.Finish()
+
+	ls.mx.Lock()
+	defer ls.mx.Unlock()
+
+	for i, r := range results {
+		v, ok := values[keys[i]]
+
+		switch {
+		case err != nil:
+			r.err = err
+			ls.forget(l, keys[i], r)
+		case !ok:
+			r.err = ErrNotFound
+		default:
+			r.value = v
+		}
+
+		close(r.done)
+	}
+}
+
+// forget Removes r from l's cache, unless key has since been loaded again.
+// Called with ls.mx held
+func (ls *loaders) forget(l *loader, key interface{}, r *result) {
+	if l.cache[key] == r {
+		delete(l.cache, key)
+	}
+}
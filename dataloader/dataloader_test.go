@@ -0,0 +1,122 @@
+package dataloader
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+// counter Fetches keys below 100 as their doubles, recording each batch
+type counter struct {
+	mx      sync.Mutex
+	batches [][]interface{}
+}
+
+func (c *counter) fetch(ctx context.Context, keys []interface{}) (map[interface{}]interface{}, error) {
+	c.mx.Lock()
+	c.batches = append(c.batches, keys)
+	c.mx.Unlock()
+
+	values := make(map[interface{}]interface{})
+	for _, k := range keys {
+		if k.(int) < 100 {
+			values[k] = k.(int) * 2
+		}
+	}
+
+	return values, nil
+}
+
+func (c *counter) sizes() string {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+
+	var sizes []int
+	for _, b := range c.batches {
+		sizes = append(sizes, len(b))
+	}
+
+	return fmt.Sprint(sizes)
+}
+
+// loadAll Loads each key at once, returning the values in order
+func loadAll(ctx context.Context, c *counter, keys ...int) ([]interface{}, []error) {
+	values := make([]interface{}, len(keys))
+	errs := make([]error, len(keys))
+
+	var wg sync.WaitGroup
+	for i, k := range keys {
+		wg.Add(1)
+		go func(i int, k int) {
+			defer wg.Done()
+			values[i], errs[i] = Load(ctx, "double", k, c.fetch)
+		}(i, k)
+	}
+	wg.Wait()
+
+	return values, errs
+}
+
+func TestLoadBatches(t *testing.T) {
+	SetConfig(Config{Wait: 10 * time.Millisecond, MaxBatch: 3})
+	ctx := NewContext(context.Background())
+	c := &counter{}
+
+	values, errs := loadAll(ctx, c, 1, 2, 2, 3, 4, 100)
+
+	if c.sizes() != "[3 2]" && c.sizes() != "[2 3]" {
+		t.Errorf("Expected batches of 3 and 2 keys, but had %s", c.sizes())
+	}
+
+	if fmt.Sprint(values[:5]) != "[2 4 4 6 8]" {
+		t.Errorf("Expected doubled values, but had %v", values)
+	}
+
+	if errs[5] != ErrNotFound {
+		t.Errorf("Expected ErrNotFound for a missing key, but had %v", errs[5])
+	}
+
+	// Values, including missing ones, are remembered until cleared
+	loadAll(ctx, c, 1, 100)
+	Clear(ctx, "double", 2)
+	loadAll(ctx, c, 2)
+
+	if c.sizes() != "[3 2 1]" && c.sizes() != "[2 3 1]" {
+		t.Errorf("Expected only the cleared key to be fetched again, but had batches %s", c.sizes())
+	}
+}
+
+func TestLoadWithoutLoaders(t *testing.T) {
+	c := &counter{}
+
+	values, _ := loadAll(context.Background(), c, 1, 1)
+
+	if fmt.Sprint(values) != "[2 2]" || c.sizes() != "[1 1]" {
+		t.Errorf("Expected each key to be fetched on its own, but had %v from batches %s", values, c.sizes())
+	}
+}
+
+func TestLoadCancelled(t *testing.T) {
+	SetConfig(Config{Wait: 20 * time.Millisecond})
+	ctx := NewContext(context.Background())
+	c := &counter{}
+
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+
+	_, err := Load(cancelled, "double", 1, c.fetch)
+	if err != context.Canceled {
+		t.Errorf("Expected a cancelled load to return straight away, but had %v", err)
+	}
+
+	v, err := Load(ctx, "double", 2, c.fetch)
+	if err != nil || v != 4 {
+		t.Errorf("Expected 4, but had %v, %v", v, err)
+	}
+
+	if c.sizes() != "[1]" {
+		t.Errorf("Expected the cancelled key to be left out of the batch, but had %s", c.sizes())
+	}
+}
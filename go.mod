@@ -1,9 +1,10 @@
 module github.com/episub/estack
 
-go 1.12
+go 1.16
 
 require (
 	cloud.google.com/go v0.37.2
+	github.com/BurntSushi/toml v0.3.1
 	github.com/99designs/gqlgen v0.8.3
 	github.com/OneOfOne/xxhash v1.2.5 // indirect
 	github.com/caarlos0/env v3.5.0+incompatible
@@ -38,5 +39,6 @@ require (
 	github.com/yashtewari/glob-intersection v0.0.0-20180916065949-5c77d914dd0b // indirect
 	gnorm.org/gnorm v1.0.0
 	golang.org/x/crypto v0.0.0-20190325154230-a5d413f7728c
+	golang.org/x/tools v0.0.0-20190312170243-e65039ee4138
 	gopkg.in/yaml.v2 v2.2.2
 )
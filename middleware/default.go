@@ -4,6 +4,7 @@ import (
 	"context"
 	"net/http"
 
+	"github.com/episub/estack/dataloader"
 	"github.com/episub/estack/store"
 	"github.com/episub/estack/validate"
 	"github.com/episub/estack/vars"
@@ -12,10 +13,12 @@ import (
 // DefaultMW Sets up items needed for most requests
 // - Adds a data object to the context, used for passing data through to OPA requests
 // - Sets validation context
+// - Adds the dataloaders that batch and remember the rows loaded during the request
 func DefaultMW(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		ctx := context.WithValue(r.Context(), vars.SharedData, store.NewDataStore())
-		ctx = validate.SetContext(r.Context())
+		ctx = validate.SetContext(ctx)
+		ctx = dataloader.NewContext(ctx)
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }